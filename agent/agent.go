@@ -0,0 +1,221 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agent reads Dialogflow CX agent exports in the JSON package
+// format i.e. the zip file produced by agents.export with
+// dataFormat=JSON_PACKAGE, or the directory it unzips to.
+//
+// References between resources in the JSON package format are by display
+// name, so the maps in Agent and Flow are keyed by display name.
+package agent
+
+// Agent is the root of an agent export.
+type Agent struct {
+	DisplayName         string   `json:"displayName"`
+	DefaultLanguageCode string   `json:"defaultLanguageCode"`
+	SupportedLanguages  []string `json:"supportedLanguageCodes"`
+	TimeZone            string   `json:"timeZone"`
+	StartFlow           string   `json:"startFlow"`
+
+	Flows       map[string]*Flow       `json:"-"`
+	Intents     map[string]*Intent     `json:"-"`
+	EntityTypes map[string]*EntityType `json:"-"`
+	Webhooks    map[string]*Webhook    `json:"-"`
+	RouteGroups map[string]*RouteGroup `json:"-"`
+}
+
+type Flow struct {
+	Name                  string          `json:"name"`
+	DisplayName           string          `json:"displayName"`
+	TransitionRoutes      []*Route        `json:"transitionRoutes"`
+	EventHandlers         []*EventHandler `json:"eventHandlers"`
+	TransitionRouteGroups []string        `json:"transitionRouteGroups"`
+
+	Pages       map[string]*Page       `json:"-"`
+	RouteGroups map[string]*RouteGroup `json:"-"`
+}
+
+type Page struct {
+	Name                  string          `json:"name"`
+	DisplayName           string          `json:"displayName"`
+	EntryFulfillment      *Fulfillment    `json:"entryFulfillment"`
+	Form                  *Form           `json:"form"`
+	TransitionRoutes      []*Route        `json:"transitionRoutes"`
+	EventHandlers         []*EventHandler `json:"eventHandlers"`
+	TransitionRouteGroups []string        `json:"transitionRouteGroups"`
+}
+
+// Parameters returns the page's form parameters.
+func (p *Page) Parameters() []*FormParameter {
+	if p.Form == nil {
+		return nil
+	}
+	return p.Form.Parameters
+}
+
+type Form struct {
+	Parameters []*FormParameter `json:"parameters"`
+}
+
+type FormParameter struct {
+	DisplayName  string        `json:"displayName"`
+	EntityType   string        `json:"entityType"`
+	Required     bool          `json:"required"`
+	IsList       bool          `json:"isList"`
+	Redact       bool          `json:"redact"`
+	DefaultValue any           `json:"defaultValue"`
+	FillBehavior *FillBehavior `json:"fillBehavior"`
+}
+
+type FillBehavior struct {
+	InitialPromptFulfillment *Fulfillment    `json:"initialPromptFulfillment"`
+	RepromptEventHandlers    []*EventHandler `json:"repromptEventHandlers"`
+}
+
+// Route is a transition route; either Intent, Condition or both are set.
+type Route struct {
+	Name               string       `json:"name"`
+	Intent             string       `json:"intent"`
+	Condition          string       `json:"condition"`
+	TriggerFulfillment *Fulfillment `json:"triggerFulfillment"`
+	TargetPage         string       `json:"targetPage"`
+	TargetFlow         string       `json:"targetFlow"`
+}
+
+type EventHandler struct {
+	Name               string       `json:"name"`
+	Event              string       `json:"event"`
+	TriggerFulfillment *Fulfillment `json:"triggerFulfillment"`
+	TargetPage         string       `json:"targetPage"`
+	TargetFlow         string       `json:"targetFlow"`
+}
+
+type RouteGroup struct {
+	Name             string   `json:"name"`
+	DisplayName      string   `json:"displayName"`
+	TransitionRoutes []*Route `json:"transitionRoutes"`
+}
+
+// Fulfillment is what the agent does when a page is entered or a route or
+// event handler is taken.  Webhook is the display name of the webhook
+// called with Tag.
+type Fulfillment struct {
+	Messages               []*Message            `json:"messages"`
+	Webhook                string                `json:"webhook"`
+	Tag                    string                `json:"tag"`
+	SetParameterActions    []*SetParameterAction `json:"setParameterActions"`
+	ReturnPartialResponses bool                  `json:"returnPartialResponses"`
+}
+
+// Message is a response message.  Only the text and payload kinds are
+// decoded.
+type Message struct {
+	Text         *Text          `json:"text"`
+	Payload      map[string]any `json:"payload"`
+	LanguageCode string         `json:"languageCode"`
+}
+
+type Text struct {
+	Text []string `json:"text"`
+}
+
+type SetParameterAction struct {
+	Parameter string `json:"parameter"`
+	Value     any    `json:"value"`
+}
+
+type Intent struct {
+	Name        string             `json:"name"`
+	DisplayName string             `json:"displayName"`
+	Priority    int                `json:"priority"`
+	IsFallback  bool               `json:"isFallback"`
+	Parameters  []*IntentParameter `json:"parameters"`
+
+	// TrainingPhrases are keyed by language code.
+	TrainingPhrases map[string][]*TrainingPhrase `json:"-"`
+}
+
+type IntentParameter struct {
+	ID         string `json:"id"`
+	EntityType string `json:"entityType"`
+	IsList     bool   `json:"isList"`
+	Redact     bool   `json:"redact"`
+}
+
+// Parameter returns the intent parameter with the given ID, or nil.
+func (i *Intent) Parameter(id string) *IntentParameter {
+	for _, p := range i.Parameters {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+type TrainingPhrase struct {
+	ID           string  `json:"id"`
+	Parts        []*Part `json:"parts"`
+	RepeatCount  int     `json:"repeatCount"`
+	LanguageCode string  `json:"languageCode"`
+}
+
+// Part is a span of a training phrase; ParameterID is set for spans
+// annotated as an intent parameter.
+type Part struct {
+	Text        string `json:"text"`
+	ParameterID string `json:"parameterId"`
+}
+
+type EntityType struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Kind        string `json:"kind"`
+
+	// Entities are keyed by language code.
+	Entities map[string][]*Entity `json:"-"`
+}
+
+type Entity struct {
+	Value    string   `json:"value"`
+	Synonyms []string `json:"synonyms"`
+}
+
+type Webhook struct {
+	Name              string             `json:"name"`
+	DisplayName       string             `json:"displayName"`
+	Disabled          bool               `json:"disabled"`
+	GenericWebService *GenericWebService `json:"genericWebService"`
+	ServiceDirectory  *ServiceDirectory  `json:"serviceDirectory"`
+}
+
+type GenericWebService struct {
+	URI string `json:"uri"`
+}
+
+type ServiceDirectory struct {
+	Service           string             `json:"service"`
+	GenericWebService *GenericWebService `json:"genericWebService"`
+}
+
+// URI returns the webhook's URI, whether it's a generic web service or a
+// Service Directory service.
+func (w *Webhook) URI() string {
+	switch {
+	case w.GenericWebService != nil:
+		return w.GenericWebService.URI
+	case w.ServiceDirectory != nil && w.ServiceDirectory.GenericWebService != nil:
+		return w.ServiceDirectory.GenericWebService.URI
+	}
+	return ""
+}
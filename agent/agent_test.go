@@ -0,0 +1,141 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func checkCoffee(t *testing.T, a *Agent) {
+	t.Helper()
+	if a.DisplayName != "Coffee" || a.DefaultLanguageCode != "en" || a.StartFlow != "Default Start Flow" {
+		t.Errorf("agent = %+v", a)
+	}
+	f := a.Flows["Default Start Flow"]
+	if f == nil || len(f.TransitionRoutes) != 2 || len(f.EventHandlers) != 2 || len(f.Pages) != 2 {
+		t.Fatalf("flow = %+v", f)
+	}
+	order := f.Pages["Order"]
+	if order == nil || len(order.Parameters()) != 2 || order.Parameters()[0].EntityType != "size" {
+		t.Fatalf("Order page = %+v", order)
+	}
+	if got := order.TransitionRoutes[0].TriggerFulfillment.Tag; got != "confirm-order" {
+		t.Errorf("Order route tag = %q, want confirm-order", got)
+	}
+	oc := a.Intents["order.coffee"]
+	if oc == nil || len(oc.TrainingPhrases["en"]) != 2 || oc.Parameter("size") == nil {
+		t.Fatalf("order.coffee = %+v", oc)
+	}
+	size := a.EntityTypes["size"]
+	if size == nil || len(size.Entities["en"]) != 3 {
+		t.Errorf("size = %+v", size)
+	}
+	if got := a.Webhooks["coffee-webhook"].URI(); got != "https://coffee.example.com/order" {
+		t.Errorf("webhook URI = %q", got)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	a, err := Load("testdata/coffee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCoffee(t, a)
+}
+
+func TestLoadZip(t *testing.T) {
+	// Zip the export under a top-level directory, as some tools do.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := filepath.WalkDir("testdata/coffee", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel("testdata", name)
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "coffee.zip")
+	err = os.WriteFile(name, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCoffee(t, a)
+}
+
+func TestReadErrors(t *testing.T) {
+	_, err := Read(fstest.MapFS{"README.md": {Data: []byte("hi")}})
+	if !errors.Is(err, ErrNoAgent) {
+		t.Errorf("err = %v, want ErrNoAgent", err)
+	}
+	_, err = Read(fstest.MapFS{
+		"agent.json":                   {Data: []byte(`{"displayName": "x"}`)},
+		"webhooks/broken-webhook.json": {Data: []byte(`{`)},
+	})
+	if err == nil {
+		t.Error("reading a malformed webhook succeeded")
+	}
+}
+
+func TestFulfillments(t *testing.T) {
+	a, err := Load("testdata/coffee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tags []string
+	for _, ref := range a.Fulfillments() {
+		if ref.Fulfillment.Tag != "" {
+			tags = append(tags, ref.Fulfillment.Tag+" @ "+ref.Location)
+		}
+	}
+	want := []string{
+		`start-order @ flow "Default Start Flow" > route "order.coffee"`,
+		`goodbye @ flow "Default Start Flow" > page "Confirm" > route "confirmation.no"`,
+		`confirm-order @ flow "Default Start Flow" > page "Order" > route if "$page.params.status = \"FINAL\""`,
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %q, want %q", tags, want)
+	}
+}
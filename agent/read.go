@@ -0,0 +1,256 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+var ErrNoAgent = errors.New("agent: agent.json not found")
+
+// Load reads an agent export from a zip file or a directory.
+func Load(name string) (*Agent, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return Read(os.DirFS(name))
+	}
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return Read(zr)
+}
+
+// Read reads an agent export from fsys.  agent.json may be at the root of
+// fsys or in a single top-level directory.
+func Read(fsys fs.FS) (*Agent, error) {
+	root, err := findRoot(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if root != "." {
+		fsys, err = fs.Sub(fsys, root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	a := new(Agent)
+	err = readJSON(fsys, "agent.json", a)
+	if err != nil {
+		return nil, err
+	}
+	a.Flows = make(map[string]*Flow)
+	a.Intents = make(map[string]*Intent)
+	a.EntityTypes = make(map[string]*EntityType)
+	a.Webhooks = make(map[string]*Webhook)
+
+	err = eachDir(fsys, "flows", func(dir string) error {
+		f := new(Flow)
+		err := readJSON(fsys, path.Join(dir, path.Base(dir)+".json"), f)
+		if err != nil {
+			return err
+		}
+		f.Pages = make(map[string]*Page)
+		err = eachFile(fsys, path.Join(dir, "pages"), func(name string) error {
+			p := new(Page)
+			err := readJSON(fsys, name, p)
+			if err != nil {
+				return err
+			}
+			f.Pages[p.DisplayName] = p
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		f.RouteGroups, err = readRouteGroups(fsys, path.Join(dir, "transitionRouteGroups"))
+		if err != nil {
+			return err
+		}
+		a.Flows[f.DisplayName] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachDir(fsys, "intents", func(dir string) error {
+		i := new(Intent)
+		err := readJSON(fsys, path.Join(dir, path.Base(dir)+".json"), i)
+		if err != nil {
+			return err
+		}
+		i.TrainingPhrases = make(map[string][]*TrainingPhrase)
+		err = eachFile(fsys, path.Join(dir, "trainingPhrases"), func(name string) error {
+			var tps struct {
+				TrainingPhrases []*TrainingPhrase `json:"trainingPhrases"`
+			}
+			err := readJSON(fsys, name, &tps)
+			if err != nil {
+				return err
+			}
+			i.TrainingPhrases[language(name)] = tps.TrainingPhrases
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		a.Intents[i.DisplayName] = i
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachDir(fsys, "entityTypes", func(dir string) error {
+		et := new(EntityType)
+		err := readJSON(fsys, path.Join(dir, path.Base(dir)+".json"), et)
+		if err != nil {
+			return err
+		}
+		et.Entities = make(map[string][]*Entity)
+		err = eachFile(fsys, path.Join(dir, "entities"), func(name string) error {
+			var es struct {
+				Entities []*Entity `json:"entities"`
+			}
+			err := readJSON(fsys, name, &es)
+			if err != nil {
+				return err
+			}
+			et.Entities[language(name)] = es.Entities
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		a.EntityTypes[et.DisplayName] = et
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachFile(fsys, "webhooks", func(name string) error {
+		w := new(Webhook)
+		err := readJSON(fsys, name, w)
+		if err != nil {
+			return err
+		}
+		a.Webhooks[w.DisplayName] = w
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.RouteGroups, err = readRouteGroups(fsys, "transitionRouteGroups")
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func findRoot(fsys fs.FS) (string, error) {
+	_, err := fs.Stat(fsys, "agent.json")
+	if err == nil {
+		return ".", nil
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		_, err := fs.Stat(fsys, path.Join(e.Name(), "agent.json"))
+		if err == nil {
+			return e.Name(), nil
+		}
+	}
+	return "", ErrNoAgent
+}
+
+func readRouteGroups(fsys fs.FS, dir string) (map[string]*RouteGroup, error) {
+	groups := make(map[string]*RouteGroup)
+	err := eachFile(fsys, dir, func(name string) error {
+		g := new(RouteGroup)
+		err := readJSON(fsys, name, g)
+		if err != nil {
+			return err
+		}
+		groups[g.DisplayName] = g
+		return nil
+	})
+	return groups, err
+}
+
+func readJSON(fsys fs.FS, name string, v any) error {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("agent: %s: %w", name, err)
+	}
+	return nil
+}
+
+// eachDir calls f with each subdirectory of dir; a missing dir is empty.
+func eachDir(fsys fs.FS, dir string, f func(string) error) error {
+	return each(fsys, dir, true, f)
+}
+
+// eachFile calls f with each .json file in dir; a missing dir is empty.
+func eachFile(fsys fs.FS, dir string, f func(string) error) error {
+	return each(fsys, dir, false, f)
+}
+
+func each(fsys fs.FS, dir string, dirs bool, f func(string) error) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() != dirs || (!dirs && path.Ext(e.Name()) != ".json") {
+			continue
+		}
+		err := f(path.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func language(name string) string {
+	return strings.TrimSuffix(path.Base(name), ".json")
+}
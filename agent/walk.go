@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FulfillmentRef is a fulfillment and where it's used in the agent e.g.
+// `flow "Default Start Flow" > page "Order" > route "$page.params.status = "FINAL""`.
+type FulfillmentRef struct {
+	Location    string
+	Fulfillment *Fulfillment
+}
+
+// Fulfillments returns every fulfillment in the agent: entry fulfillments,
+// routes, event handlers, form parameter prompts and reprompts, and route
+// groups.  The order is deterministic.
+func (a *Agent) Fulfillments() []FulfillmentRef {
+	var refs []FulfillmentRef
+	add := func(loc string, f *Fulfillment) {
+		if f != nil {
+			refs = append(refs, FulfillmentRef{Location: loc, Fulfillment: f})
+		}
+	}
+	routes := func(loc string, rs []*Route) {
+		for _, r := range rs {
+			add(loc+" > "+routeName(r), r.TriggerFulfillment)
+		}
+	}
+	events := func(loc string, hs []*EventHandler) {
+		for _, h := range hs {
+			add(fmt.Sprintf("%s > event %q", loc, h.Event), h.TriggerFulfillment)
+		}
+	}
+	groups := func(loc string, gs map[string]*RouteGroup) {
+		for _, name := range sortedKeys(gs) {
+			routes(fmt.Sprintf("%sroute group %q", loc, name), gs[name].TransitionRoutes)
+		}
+	}
+
+	for _, fname := range sortedKeys(a.Flows) {
+		f := a.Flows[fname]
+		floc := fmt.Sprintf("flow %q", fname)
+		routes(floc, f.TransitionRoutes)
+		events(floc, f.EventHandlers)
+		groups(floc+" > ", f.RouteGroups)
+		for _, pname := range sortedKeys(f.Pages) {
+			p := f.Pages[pname]
+			ploc := fmt.Sprintf("%s > page %q", floc, pname)
+			add(ploc+" > entry", p.EntryFulfillment)
+			for _, param := range p.Parameters() {
+				if param.FillBehavior == nil {
+					continue
+				}
+				pploc := fmt.Sprintf("%s > parameter %q", ploc, param.DisplayName)
+				add(pploc+" > initial prompt", param.FillBehavior.InitialPromptFulfillment)
+				events(pploc, param.FillBehavior.RepromptEventHandlers)
+			}
+			routes(ploc, p.TransitionRoutes)
+			events(ploc, p.EventHandlers)
+		}
+	}
+	groups("", a.RouteGroups)
+	return refs
+}
+
+func routeName(r *Route) string {
+	switch {
+	case r.Intent != "" && r.Condition != "":
+		return fmt.Sprintf("route %q if %q", r.Intent, r.Condition)
+	case r.Intent != "":
+		return fmt.Sprintf("route %q", r.Intent)
+	}
+	return fmt.Sprintf("route if %q", r.Condition)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit keeps a durable record of every webhook turn.
+//
+// The audit Middleware writes one Record per turn to a Sink.  Records are
+// encoded as newline delimited JSON (NDJSON) with the WebhookRequest and
+// WebhookResponse embedded as protojson.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Record is the audit record of a single webhook turn.
+type Record struct {
+	Timestamp              time.Time       `json:"timestamp"`
+	Session                string          `json:"session,omitempty"`
+	Tag                    string          `json:"tag,omitempty"`
+	DetectIntentResponseID string          `json:"detectIntentResponseId,omitempty"`
+	Request                json.RawMessage `json:"request,omitempty"`
+	Response               json.RawMessage `json:"response,omitempty"`
+	LatencyMs              float64         `json:"latencyMs"`
+	Error                  string          `json:"error,omitempty"`
+}
+
+// Encode returns the Record as a single line of JSON terminated by a
+// newline.
+func (rec *Record) Encode() ([]byte, error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// Redactor is applied to the request, response and error before a Record is
+// written.  *redact.Policy satisfies Redactor.
+type Redactor interface {
+	Redact(*ezcx.WebhookRequest) *ezcx.WebhookRequest
+	RedactResponse(*ezcx.WebhookResponse) *ezcx.WebhookResponse
+	Error(error) error
+}
+
+// NewRecord builds the Record of a turn.  If r is non-nil, it's applied
+// before the request and response are marshalled.
+func NewRecord(req *ezcx.WebhookRequest, res *ezcx.WebhookResponse, latency time.Duration, handlerErr error, r Redactor) (*Record, error) {
+	if r != nil {
+		req = r.Redact(req)
+		res = r.RedactResponse(res)
+		handlerErr = r.Error(handlerErr)
+	}
+	rec := &Record{
+		Timestamp:              time.Now().UTC(),
+		Session:                req.GetSessionInfo().GetSession(),
+		Tag:                    req.GetFulfillmentInfo().GetTag(),
+		DetectIntentResponseID: req.GetDetectIntentResponseId(),
+		LatencyMs:              float64(latency) / float64(time.Millisecond),
+	}
+	var err error
+	rec.Request, err = protojson.Marshal(&req.WebhookRequest)
+	if err != nil {
+		return nil, err
+	}
+	rec.Response, err = protojson.Marshal(&res.WebhookResponse)
+	if err != nil {
+		return nil, err
+	}
+	if handlerErr != nil {
+		rec.Error = handlerErr.Error()
+	}
+	return rec, nil
+}
+
+// Middleware returns an ezcx.Middleware that writes a Record of every turn
+// to sink after the handler returns.  If r is non-nil, it's applied before
+// anything is written.  Failing to write a Record doesn't fail the turn; the
+// failure is logged via req.Logger().
+func Middleware(sink Sink, r Redactor) ezcx.Middleware {
+	return func(next ezcx.HandlerFunc) ezcx.HandlerFunc {
+		return func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+			start := time.Now()
+			err := next(res, req)
+			latency := time.Since(start)
+
+			rec, recErr := NewRecord(req, res, latency, err, r)
+			if recErr == nil {
+				recErr = sink.Write(rec)
+			}
+			if recErr != nil {
+				req.Logger().Error("audit: unable to write record", "error", recErr)
+			}
+			return err
+		}
+	}
+}
+
+// ReadRecords decodes the NDJSON Records in r.  Blank lines are skipped.
+func ReadRecords(r io.Reader) ([]*Record, error) {
+	var recs []*Record
+	dec := json.NewDecoder(r)
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return recs, nil
+		}
+		if err != nil {
+			return recs, fmt.Errorf("audit: record %d: %w", len(recs)+1, err)
+		}
+		recs = append(recs, &rec)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/redact"
+)
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	h := ezcx.Chain(func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		res.AddTextResponse("We'll call 650-253-0000")
+		return errors.New("sms to 650-253-0000 failed")
+	}, Middleware(NewWriterSink(&buf), redact.DefaultPolicy()))
+
+	req, err := ezcx.NewTestingWebhookRequest(map[string]any{"email": "jane.doe@example.com"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := req.InitializeResponse()
+	if h(res, req) == nil {
+		t.Fatal("handler error should be returned")
+	}
+
+	out := buf.String()
+	if strings.Count(out, "\n") != 1 {
+		t.Fatalf("want a single NDJSON line, got:\n%s", out)
+	}
+	for _, leak := range []string{"650-253-0000", "jane.doe@example.com"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("%s leaked: %s", leak, out)
+		}
+	}
+	var rec Record
+	err = json.Unmarshal(buf.Bytes(), &rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Session != req.SessionInfo.Session || rec.Error == "" || rec.Request == nil || rec.Response == nil {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.ndjson")
+	s, err := NewFileSink(path, RotateOptions{MaxSize: 200, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		err = s.Write(&Record{Tag: strings.Repeat("x", 100)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "audit-*.ndjson.gz"))
+	if len(rotated) != 4 {
+		t.Fatalf("got %d rotated files, want 4", len(rotated))
+	}
+	f, err := os.Open(rotated[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(zr)
+	lines := 0
+	for sc.Scan() {
+		lines++
+	}
+	if lines != 1 {
+		t.Errorf("got %d lines in %s, want 1", lines, rotated[0])
+	}
+	if uncompressed, _ := filepath.Glob(filepath.Join(dir, "audit-*.ndjson")); len(uncompressed) != 0 {
+		t.Errorf("uncompressed rotated files left behind: %v", uncompressed)
+	}
+}
+
+func TestFileSinkAgeRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.ndjson")
+	s, err := NewFileSink(path, RotateOptions{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2022, 11, 28, 15, 4, 5, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	s.opened = now
+	s.Write(&Record{})
+	now = now.Add(2 * time.Hour)
+	s.Write(&Record{})
+	s.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "audit-20221128T170405.000.ndjson")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFileSinkRotationFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.ndjson")
+	s, err := NewFileSink(path, RotateOptions{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Write(&Record{Tag: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.rename = func(string, string) error { return errors.New("rename failed") }
+	err = s.Write(&Record{Tag: "x"})
+	if err == nil {
+		t.Fatal("got nil error from a failed rotation")
+	}
+	s.rename = os.Rename
+	err = s.Write(&Record{Tag: "x"})
+	if err != nil {
+		t.Fatalf("write after a failed rotation: %v", err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	if n := strings.Count(string(b), "\n"); n != 1 {
+		t.Errorf("got %d records in %s, want 1", n, path)
+	}
+}
+
+type blockingSink struct {
+	release chan struct{}
+	buf     bytes.Buffer
+}
+
+func (s *blockingSink) Write(rec *Record) error {
+	<-s.release
+	b, _ := rec.Encode()
+	s.buf.Write(b)
+	return nil
+}
+
+func (s *blockingSink) Close() error { return nil }
+
+func TestAsyncSinkBackpressure(t *testing.T) {
+	next := &blockingSink{release: make(chan struct{})}
+	s := NewAsyncSink(next, 1, 10*time.Millisecond, nil)
+
+	// The first Record is picked up by the writer goroutine (and blocks), the
+	// second fills the buffer and the third times out.
+	var errs []error
+	for i := 0; i < 3; i++ {
+		errs = append(errs, s.Write(&Record{}))
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !errors.Is(errs[2], ErrBufferFull) || s.Dropped() != 1 {
+		t.Errorf("got errs %v, dropped %d", errs, s.Dropped())
+	}
+	close(next.release)
+	err := s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(next.buf.String(), "\n"); n != 2 {
+		t.Errorf("got %d records, want 2", n)
+	}
+	if err := s.Write(&Record{}); !errors.Is(err, ErrSinkClosed) {
+		t.Errorf("write after close: got %v", err)
+	}
+}
+
+type failingSink struct{ n int }
+
+func (s *failingSink) Write(*Record) error {
+	s.n++
+	return fmt.Errorf("write %d failed", s.n)
+}
+
+func (s *failingSink) Close() error { return nil }
+
+func TestAsyncSinkErrors(t *testing.T) {
+	var logs bytes.Buffer
+	s := NewAsyncSink(&failingSink{}, 4, 0, slog.New(slog.NewJSONHandler(&logs, nil)))
+	for i := 0; i < 3; i++ {
+		if err := s.Write(&Record{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.Close()
+	if s.Failed() != 3 {
+		t.Errorf("failed = %d, want 3", s.Failed())
+	}
+	if err == nil || !strings.Contains(err.Error(), "write 1 failed") || !strings.Contains(err.Error(), "write 3 failed") || strings.Contains(err.Error(), "write 2 failed") {
+		t.Errorf("Close() = %v, want the first and last errors", err)
+	}
+	if n := strings.Count(logs.String(), `"error":"write `); n != 3 {
+		t.Errorf("logged %d errors, want 3:\n%s", n, logs.String())
+	}
+}
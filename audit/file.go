@@ -0,0 +1,230 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateOptions configures the rotation of a FileSink.  A zero MaxSize or
+// MaxAge disables the corresponding rotation trigger.
+type RotateOptions struct {
+	// MaxSize is the size in bytes after which the file is rotated.
+	MaxSize int64
+	// MaxAge is the time after which the file is rotated.
+	MaxAge time.Duration
+	// Compress gzips rotated files.
+	Compress bool
+}
+
+// FileSink writes NDJSON Records to a local file, rotating it by size and
+// age.  Rotated files are renamed with a UTC timestamp suffix, e.g.
+// audit.ndjson becomes audit-20221128T150405.000.ndjson (.gz when
+// compressed).
+type FileSink struct {
+	mu     sync.Mutex
+	path   string
+	opts   RotateOptions
+	f      *os.File // nil after a failed rotation until it's reopened
+	closed bool
+	size   int64
+	opened time.Time
+	wg     sync.WaitGroup
+	errs   chan error
+	now    func() time.Time
+	rename func(oldpath, newpath string) error
+}
+
+// NewFileSink opens (or creates) the file at path for appending.
+func NewFileSink(path string, opts RotateOptions) (*FileSink, error) {
+	s := &FileSink{
+		path:   path,
+		opts:   opts,
+		errs:   make(chan error, 16),
+		now:    time.Now,
+		rename: os.Rename,
+	}
+	err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	err := os.MkdirAll(filepath.Dir(s.path), 0o755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f = f
+	s.size = info.Size()
+	s.opened = s.now()
+	return nil
+}
+
+func (s *FileSink) Write(rec *Record) error {
+	b, err := rec.Encode()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	if s.f == nil {
+		// The last rotation failed; append to the file at path again.
+		err = s.open()
+		if err != nil {
+			return err
+		}
+	}
+	if s.shouldRotate(int64(len(b))) {
+		err = s.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) shouldRotate(n int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.opts.MaxSize > 0 && s.size+n > s.opts.MaxSize {
+		return true
+	}
+	if s.opts.MaxAge > 0 && s.now().Sub(s.opened) >= s.opts.MaxAge {
+		return true
+	}
+	return false
+}
+
+// rotate must be called with s.mu held.  If it fails, s.f is left nil and
+// the next Write reopens the file at path.
+func (s *FileSink) rotate() error {
+	err := s.f.Close()
+	s.f = nil
+	if err != nil {
+		return err
+	}
+	rotated := s.rotatedName()
+	err = s.rename(s.path, rotated)
+	if err != nil {
+		return err
+	}
+	if s.opts.Compress {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := compress(rotated); err != nil {
+				select {
+				case s.errs <- err:
+				default:
+				}
+			}
+		}()
+	}
+	return s.open()
+}
+
+// rotatedName returns an unused name for the rotated file; a counter is
+// added when rotating more than once within the same millisecond.
+func (s *FileSink) rotatedName() string {
+	ext := filepath.Ext(s.path)
+	base := strings.TrimSuffix(s.path, ext) + "-" + s.now().UTC().Format("20060102T150405.000")
+	name := base + ext
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	return name
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func compress(path string) (err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err != nil {
+		return err
+	}
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Close closes the current file and waits for pending compressions.  Any
+// compression errors are joined and returned.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSinkClosed
+	}
+	s.closed = true
+	var err error
+	if s.f != nil {
+		err = s.f.Close()
+		s.f = nil
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	close(s.errs)
+	errs := []error{err}
+	for e := range s.errs {
+		errs = append(errs, e)
+	}
+	return errors.Join(errs...)
+}
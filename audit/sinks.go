@@ -0,0 +1,190 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sink is the destination of audit Records.  Implementations must be safe
+// for concurrent use.
+type Sink interface {
+	Write(rec *Record) error
+	Close() error
+}
+
+var (
+	ErrSinkClosed = errors.New("audit: sink is closed")
+	ErrBufferFull = errors.New("audit: async sink buffer is full")
+)
+
+// WriterSink writes NDJSON Records to an io.Writer.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a Sink that writes to w.  Close is a no-op; closing
+// w is left to the caller.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Stdout returns a Sink that writes to os.Stdout.
+func Stdout() *WriterSink {
+	return NewWriterSink(os.Stdout)
+}
+
+func (s *WriterSink) Write(rec *Record) error {
+	b, err := rec.Encode()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// AsyncSink buffers Records and writes them to another Sink on a separate
+// goroutine so that webhook latency isn't tied to the Sink's.
+type AsyncSink struct {
+	next    Sink
+	records chan *Record
+	timeout time.Duration
+	lg      *slog.Logger
+	done    chan struct{}
+	dropped atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+
+	errMu  sync.Mutex
+	failed int64
+	first  error
+	last   error
+}
+
+// NewAsyncSink returns an AsyncSink that buffers up to size Records for next.
+// When the buffer is full, Write applies backpressure: it blocks until there's
+// room or, if timeout is positive, until timeout elapses in which case the
+// Record is dropped and ErrBufferFull is returned.  Failed writes are logged
+// via lg, or slog.Default() if lg is nil.
+func NewAsyncSink(next Sink, size int, timeout time.Duration, lg *slog.Logger) *AsyncSink {
+	if lg == nil {
+		lg = slog.Default()
+	}
+	s := &AsyncSink{
+		next:    next,
+		records: make(chan *Record, size),
+		timeout: timeout,
+		lg:      lg,
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *AsyncSink) run() {
+	defer close(s.done)
+	for rec := range s.records {
+		err := s.next.Write(rec)
+		if err != nil {
+			s.lg.Error("audit: async sink write failed", "error", err)
+			s.errMu.Lock()
+			s.failed++
+			if s.first == nil {
+				s.first = err
+			}
+			s.last = err
+			s.errMu.Unlock()
+		}
+	}
+}
+
+func (s *AsyncSink) Write(rec *Record) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	if s.timeout <= 0 {
+		s.records <- rec
+		return nil
+	}
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	select {
+	case s.records <- rec:
+		return nil
+	case <-timer.C:
+		s.dropped.Add(1)
+		return ErrBufferFull
+	}
+}
+
+// Dropped returns the number of Records dropped because the buffer was full.
+func (s *AsyncSink) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Failed returns the number of Records the underlying Sink failed to write.
+// Each failure is also logged as it happens.
+func (s *AsyncSink) Failed() int64 {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return s.failed
+}
+
+// Err reports the first and last errors returned by the underlying Sink's
+// Write, or nil if every write succeeded.
+func (s *AsyncSink) Err() error {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	switch s.failed {
+	case 0:
+		return nil
+	case 1:
+		return s.first
+	}
+	return fmt.Errorf("audit: %d writes failed; first: %w; last: %w", s.failed, s.first, s.last)
+}
+
+// Close flushes the buffered Records and closes the underlying Sink.  It
+// returns Err joined with the underlying Sink's Close error.
+func (s *AsyncSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSinkClosed
+	}
+	s.closed = true
+	close(s.records)
+	s.mu.Unlock()
+
+	<-s.done
+	return errors.Join(s.Err(), s.next.Close())
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+import (
+	"encoding/json"
+
+	cxbeta "cloud.google.com/go/dialogflow/cx/apiv3beta1/cxpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Beta returns the request as a v3beta1 WebhookRequest for agents that call
+// the webhook with beta-only fields; handlers otherwise work with the v3
+// protos whichever API version the agent uses.  It's decoded from the JSON
+// the request was read from, so beta-only fields such as toolCall messages
+// are kept; fields neither version knows are dropped.  Requests built
+// in code are converted from their v3 fields.  The result is cached; it
+// doesn't reflect later changes to the request.
+func (req *WebhookRequest) Beta() (*cxbeta.WebhookRequest, error) {
+	if req.beta != nil {
+		return req.beta, nil
+	}
+	b := req.raw
+	if b == nil {
+		var err error
+		b, err = protojson.Marshal(&req.WebhookRequest)
+		if err != nil {
+			return nil, err
+		}
+	}
+	beta := new(cxbeta.WebhookRequest)
+	unmarshaler := protojson.UnmarshalOptions{AllowPartial: true, DiscardUnknown: true}
+	err := unmarshaler.Unmarshal(b, beta)
+	if err != nil {
+		return nil, ErrUnmarshalWrapper("Beta", err)
+	}
+	req.beta = beta
+	return beta, nil
+}
+
+// Beta returns a v3beta1 WebhookResponse for setting beta-only fields.
+// When the response is written, it's merged over the v3 response with
+// proto.Merge: fields set in both take Beta's value, except that lists are
+// appended.
+func (res *WebhookResponse) Beta() *cxbeta.WebhookResponse {
+	if res.beta == nil {
+		res.beta = new(cxbeta.WebhookResponse)
+	}
+	return res.beta
+}
+
+// mergeBeta returns the v3 response converted to v3beta1 with the Beta
+// fields merged in.
+func (res *WebhookResponse) mergeBeta() (*cxbeta.WebhookResponse, error) {
+	b, err := protojson.Marshal(&res.WebhookResponse)
+	if err != nil {
+		return nil, err
+	}
+	merged := new(cxbeta.WebhookResponse)
+	err = protojson.Unmarshal(b, merged)
+	if err != nil {
+		return nil, err
+	}
+	proto.Merge(merged, res.beta)
+	return merged, nil
+}
+
+type snapshot struct {
+	V3   json.RawMessage `json:"v3"`
+	Beta json.RawMessage `json:"beta,omitempty"`
+}
+
+// Snapshot returns the response, including any Beta fields, as JSON that
+// Restore reads back.  Middleware that replays responses, e.g. a cache,
+// stores snapshots so that beta-only content isn't lost.
+func (res *WebhookResponse) Snapshot() ([]byte, error) {
+	var snap snapshot
+	var err error
+	snap.V3, err = protojson.Marshal(&res.WebhookResponse)
+	if err != nil {
+		return nil, err
+	}
+	if res.beta != nil {
+		snap.Beta, err = protojson.Marshal(res.beta)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(snap)
+}
+
+// Restore replaces the response, including its Beta fields, with the
+// snapshot b.  A plain v3 WebhookResponse in JSON is accepted too.
+func (res *WebhookResponse) Restore(b []byte) error {
+	var snap snapshot
+	err := json.Unmarshal(b, &snap)
+	if err != nil {
+		return ErrUnmarshalWrapper("Restore", err)
+	}
+	if snap.V3 == nil {
+		snap.V3 = b
+	}
+	proto.Reset(&res.WebhookResponse)
+	res.beta = nil
+	err = protojson.Unmarshal(snap.V3, &res.WebhookResponse)
+	if err != nil {
+		return ErrUnmarshalWrapper("Restore", err)
+	}
+	if snap.Beta != nil {
+		res.beta = new(cxbeta.WebhookResponse)
+		err = protojson.Unmarshal(snap.Beta, res.beta)
+		if err != nil {
+			return ErrUnmarshalWrapper("Restore", err)
+		}
+	}
+	return nil
+}
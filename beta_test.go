@@ -0,0 +1,174 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	cxbeta "cloud.google.com/go/dialogflow/cx/apiv3beta1/cxpb"
+)
+
+func TestBetaRequest(t *testing.T) {
+	req, err := WebhookRequestFromReader(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	beta, err := req.Beta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if beta.GetSessionInfo().GetSession() != req.GetSessionInfo().GetSession() || beta.GetFulfillmentInfo().GetTag() != req.GetFulfillmentInfo().GetTag() {
+		t.Errorf("beta = %v", beta)
+	}
+	if again, _ := req.Beta(); again != beta {
+		t.Error("Beta() isn't cached")
+	}
+
+	built := NewWebhookRequest()
+	built.LanguageCode = "de"
+	beta, err = built.Beta()
+	if err != nil || beta.GetLanguageCode() != "de" {
+		t.Errorf("built.Beta() = %v, %v", beta, err)
+	}
+}
+
+// betaSample has a toolCall message, which only the v3beta1 protos define.
+const betaSample = `{
+	"detectIntentResponseId": "d",
+	"fulfillmentInfo": {"tag": "order"},
+	"sessionInfo": {"session": "projects/p/locations/global/agents/a/sessions/s"},
+	"messages": [
+		{"text": {"text": ["hi"]}},
+		{"toolCall": {"tool": "projects/p/locations/global/agents/a/tools/t", "action": "lookup", "inputParameters": {"sku": "latte"}}}
+	]
+}`
+
+func TestBetaOnlyFields(t *testing.T) {
+	req, err := WebhookRequestFromReader(strings.NewReader(betaSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(req.GetMessages()); n != 2 || req.GetMessages()[1].GetMessage() != nil {
+		t.Fatalf("v3 messages = %v, want the toolCall dropped", req.GetMessages())
+	}
+	beta, err := req.Beta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := beta.GetMessages()[1].GetToolCall()
+	if call.GetAction() != "lookup" || call.GetInputParameters().GetFields()["sku"].GetStringValue() != "latte" {
+		t.Errorf("beta toolCall = %v", call)
+	}
+
+	res := req.InitializeResponse()
+	res.AddTextResponse("checking")
+	res.Beta().FulfillmentResponse = &cxbeta.WebhookResponse_FulfillmentResponse{Messages: []*cxbeta.ResponseMessage{{
+		Message: &cxbeta.ResponseMessage_ToolCall{ToolCall: &cxbeta.ToolCall{Tool: call.GetTool(), Action: "order"}},
+	}}}
+	var buf bytes.Buffer
+	err = res.WriteResponse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		FulfillmentResponse struct {
+			Messages []struct {
+				Text     *struct{ Text []string }
+				ToolCall *struct{ Tool, Action string }
+			}
+		}
+	}
+	err = json.Unmarshal(buf.Bytes(), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := got.FulfillmentResponse.Messages
+	if len(msgs) != 2 || msgs[0].Text == nil || msgs[1].ToolCall == nil || msgs[1].ToolCall.Action != "order" {
+		t.Errorf("response = %s", &buf)
+	}
+}
+
+func TestBetaResponse(t *testing.T) {
+	res := NewWebhookResponse()
+	res.AddTextResponse("v3")
+	err := res.AddPayload(map[string]any{"v3": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Beta().PageInfo = &cxbeta.PageInfo{CurrentPage: "beta-page"}
+
+	var buf bytes.Buffer
+	err = res.WriteResponse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		FulfillmentResponse struct{ Messages []any }
+		PageInfo            struct{ CurrentPage string }
+		Payload             map[string]any
+	}
+	err = json.Unmarshal(buf.Bytes(), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.FulfillmentResponse.Messages) != 1 || got.PageInfo.CurrentPage != "beta-page" || got.Payload["v3"] != true {
+		t.Errorf("response = %s", &buf)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	res := NewWebhookResponse()
+	res.AddTextResponse("v3")
+	res.Beta().FulfillmentResponse = &cxbeta.WebhookResponse_FulfillmentResponse{Messages: []*cxbeta.ResponseMessage{{
+		Message: &cxbeta.ResponseMessage_ToolCall{ToolCall: &cxbeta.ToolCall{Action: "order"}},
+	}}}
+	b, err := res.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := NewWebhookResponse()
+	replayed.Beta().PageInfo = &cxbeta.PageInfo{CurrentPage: "stale"}
+	err = replayed.Restore(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = replayed.WriteResponse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"order"`) || !strings.Contains(out, `"v3"`) || strings.Contains(out, "stale") {
+		t.Errorf("replayed response = %s", out)
+	}
+
+	// A plain v3 response clears the Beta fields.
+	err = replayed.Restore([]byte(`{"fulfillmentResponse": {"messages": [{"text": {"text": ["plain"]}}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	err = replayed.WriteResponse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "plain") || strings.Contains(out, "order") {
+		t.Errorf("replayed v3 response = %s", out)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache caches the responses of deterministic, read-only handlers.
+//
+// A handler whose response depends only on a few request fields, e.g.
+// store hours by city, declares them as Fields; responses are cached by
+// the tag and the fields' values.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/sessionstore"
+)
+
+// Field is part of a cache key.
+type Field struct {
+	name  string
+	value func(*ezcx.WebhookRequest) any
+}
+
+// Language is the request's language code.
+func Language() Field {
+	return Field{"language", func(req *ezcx.WebhookRequest) any { return req.GetLanguageCode() }}
+}
+
+// Text is the end-user's input text or transcript.
+func Text() Field {
+	return Field{"text", func(req *ezcx.WebhookRequest) any {
+		if s := req.GetText(); s != "" {
+			return s
+		}
+		return req.GetTranscript()
+	}}
+}
+
+// Intent is the matched intent's display name.
+func Intent() Field {
+	return Field{"intent", func(req *ezcx.WebhookRequest) any { return req.GetIntentInfo().GetDisplayName() }}
+}
+
+// Page is the current page's resource name.
+func Page() Field {
+	return Field{"page", func(req *ezcx.WebhookRequest) any { return req.GetPageInfo().GetCurrentPage() }}
+}
+
+// SessionParam is the value of a session parameter.
+func SessionParam(name string) Field {
+	return Field{"session." + name, func(req *ezcx.WebhookRequest) any {
+		v, _ := req.GetSessionParameter(name)
+		return v
+	}}
+}
+
+// FormParam is the value of a form parameter.
+func FormParam(name string) Field {
+	return Field{"form." + name, func(req *ezcx.WebhookRequest) any { return req.GetPageFormParameters()[name] }}
+}
+
+// IntentParam is the resolved value of a matched intent's parameter.
+func IntentParam(name string) Field {
+	return Field{"intent." + name, func(req *ezcx.WebhookRequest) any {
+		return req.GetIntentInfo().GetParameters()[name].GetResolvedValue().AsInterface()
+	}}
+}
+
+// PayloadParam is the value of a payload field.
+func PayloadParam(name string) Field {
+	return Field{"payload." + name, func(req *ezcx.WebhookRequest) any {
+		v, _ := req.GetPayloadParameter(name)
+		return v
+	}}
+}
+
+// Key returns the cache key of req: its tag followed by the fields.
+func Key(req *ezcx.WebhookRequest, fields ...Field) (string, error) {
+	kv := make([]any, 0, 1+2*len(fields))
+	kv = append(kv, req.GetFulfillmentInfo().GetTag())
+	for _, f := range fields {
+		kv = append(kv, f.name, f.value(req))
+	}
+	b, err := json.Marshal(kv)
+	return string(b), err
+}
+
+type flight struct {
+	done chan struct{}
+	res  []byte
+	err  error
+}
+
+// Cache holds responses in memory.
+type Cache struct {
+	store *sessionstore.Memory
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// New returns a Cache holding at most size responses, each for ttl.
+func New(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		store:   sessionstore.NewMemory(size),
+		ttl:     ttl,
+		flights: make(map[string]*flight),
+	}
+}
+
+// Len returns the number of cached responses.
+func (c *Cache) Len() int {
+	return c.store.Len()
+}
+
+// Middleware returns an ezcx.Middleware that caches successful responses
+// by Key(req, fields...).  Concurrent misses for the same key run the
+// handler once.  Cached responses carry the current request's session
+// name; session and form parameters set by the handler are replayed as-is,
+// so they must also depend only on the key.
+func (c *Cache) Middleware(fields ...Field) ezcx.Middleware {
+	return func(next ezcx.HandlerFunc) ezcx.HandlerFunc {
+		return func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+			key, err := Key(req, fields...)
+			if err != nil {
+				req.Logger().Error("cache: unable to compute key", "error", err)
+				return next(res, req)
+			}
+			ctx := context.Background()
+			if b, err := c.store.Get(ctx, "", key); err == nil {
+				return restore(res, req, b)
+			}
+
+			c.mu.Lock()
+			if f, ok := c.flights[key]; ok {
+				c.mu.Unlock()
+				select {
+				case <-f.done:
+				case <-req.Context().Done():
+					return req.Context().Err()
+				}
+				if f.err != nil {
+					return f.err
+				}
+				return restore(res, req, f.res)
+			}
+			// A flight may have stored the response and finished between
+			// the Get above and taking c.mu.
+			if b, err := c.store.Get(ctx, "", key); err == nil {
+				c.mu.Unlock()
+				return restore(res, req, b)
+			}
+			f := &flight{done: make(chan struct{})}
+			c.flights[key] = f
+			c.mu.Unlock()
+			defer func() {
+				c.mu.Lock()
+				delete(c.flights, key)
+				c.mu.Unlock()
+				close(f.done)
+			}()
+
+			f.err = next(res, req)
+			if f.err != nil {
+				return f.err
+			}
+			f.res, f.err = res.Snapshot()
+			if f.err != nil {
+				// The handler succeeded; only the waiters fail.
+				req.Logger().Error("cache: unable to marshal response", "error", f.err)
+				return nil
+			}
+			c.store.Set(ctx, "", key, f.res, c.ttl)
+			return nil
+		}
+	}
+}
+
+// restore sets res to the cached response b with req's session name.
+func restore(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest, b []byte) error {
+	err := res.Restore(b)
+	if err != nil {
+		return err
+	}
+	if res.SessionInfo != nil {
+		res.SessionInfo.Session = req.GetSessionInfo().GetSession()
+	}
+	if beta := res.Beta(); beta.SessionInfo != nil {
+		beta.SessionInfo.Session = req.GetSessionInfo().GetSession()
+	}
+	return nil
+}
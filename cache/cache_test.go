@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/ezcxtest"
+)
+
+func storeHours(calls *atomic.Int32) ezcx.HandlerFunc {
+	return func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		calls.Add(1)
+		city, _ := req.GetSessionParameter("city")
+		res.AddTextResponse(fmt.Sprintf("%v opens at 9", city))
+		return nil
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	c := New(10, time.Minute)
+	h := ezcx.Chain(storeHours(&calls), c.Middleware(SessionParam("city")))
+
+	turn := func(session, city string) *ezcx.WebhookResponse {
+		t.Helper()
+		req := ezcxtest.NewRequest().SessionID(session).Tag("hours").SessionParam("city", city).SessionParam("other", session).MustBuild()
+		res := req.InitializeResponse()
+		err := h(res, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := res.GetSessionInfo().GetSession(), req.GetSessionInfo().GetSession(); got != want {
+			t.Errorf("session = %q, want %q", got, want)
+		}
+		return res
+	}
+	ezcxtest.AssertText(t, turn("s1", "Austin"), "Austin opens at 9")
+	ezcxtest.AssertText(t, turn("s2", "Austin"), "Austin opens at 9")
+	ezcxtest.AssertText(t, turn("s3", "Boston"), "Boston opens at 9")
+	if calls.Load() != 2 {
+		t.Errorf("handler ran %d times", calls.Load())
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d", c.Len())
+	}
+}
+
+func TestMiddlewareBounds(t *testing.T) {
+	var calls atomic.Int32
+	c := New(1, 20*time.Millisecond)
+	h := ezcx.Chain(storeHours(&calls), c.Middleware(SessionParam("city")))
+	for _, city := range []string{"Austin", "Boston", "Austin"} {
+		req := ezcxtest.NewRequest().SessionParam("city", city).MustBuild()
+		h(req.InitializeResponse(), req)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("size bound: handler ran %d times", calls.Load())
+	}
+	time.Sleep(40 * time.Millisecond)
+	req := ezcxtest.NewRequest().SessionParam("city", "Austin").MustBuild()
+	h(req.InitializeResponse(), req)
+	if calls.Load() != 4 {
+		t.Errorf("ttl: handler ran %d times", calls.Load())
+	}
+}
+
+func TestMiddlewareSingleflight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	slow := func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		<-release
+		return storeHours(&calls)(res, req)
+	}
+	h := ezcx.Chain(slow, New(10, time.Minute).Middleware(SessionParam("city")))
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := ezcxtest.NewRequest().SessionParam("city", "Austin").MustBuild()
+			res := req.InitializeResponse()
+			err := h(res, req)
+			if err != nil {
+				t.Error(err)
+			}
+			ezcxtest.AssertText(t, res, "Austin opens at 9")
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("handler ran %d times", calls.Load())
+	}
+}
+
+func TestKey(t *testing.T) {
+	req := ezcxtest.NewRequest().Tag("hours").Language("fr").SessionParam("city", "Paris").MustBuild()
+	got, err := Key(req, Language(), SessionParam("city"), SessionParam("missing"))
+	want := `["hours","language","fr","session.city","Paris","session.missing",null]`
+	if err != nil || got != want {
+		t.Errorf("Key = %s, %v, want %s", got, err, want)
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/googlecloudplatform/ezcx/agent"
+)
+
+const genUsage = `Usage: ezcx gen [flags] <agent export zip or directory>
+
+Gen generates Go code from an agent export:
+
+	params_gen.go  a struct per page form and per intent with parameters,
+	               with Go types derived from entity types, and a string
+	               enum per custom entity type
+	tags_gen.go    a constant per webhook tag
+	handlers.go    stub handlers, one per tag (only with -stubs, and only
+	               if the file doesn't exist)
+
+Regenerate after changing the agent; renamed parameters and tags then
+show up as compile errors.
+
+Flags:
+`
+
+const genHeader = "// Code generated by ezcx gen. DO NOT EDIT.\n\n"
+
+func runGen(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	pkg := fs.String("pkg", "main", "package `name`")
+	out := fs.String("o", ".", "output `directory`")
+	stubs := fs.Bool("stubs", false, "write stub handlers to handlers.go if it doesn't exist")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), genUsage)
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	a, err := agent.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "ezcx gen: %v\n", err)
+		return 1
+	}
+	g := newGenerator(a, *pkg)
+	files := map[string][]byte{
+		"params_gen.go": g.params(),
+		"tags_gen.go":   g.tags(),
+	}
+	if *stubs {
+		_, err := os.Stat(filepath.Join(*out, "handlers.go"))
+		if errors.Is(err, os.ErrNotExist) {
+			files["handlers.go"], err = g.handlers()
+			if err != nil {
+				fmt.Fprintf(stderr, "ezcx gen: %v\n", err)
+				return 1
+			}
+		}
+	}
+	err = os.MkdirAll(*out, 0755)
+	if err != nil {
+		fmt.Fprintf(stderr, "ezcx gen: %v\n", err)
+		return 1
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b, err := format.Source(files[name])
+		if err != nil {
+			fmt.Fprintf(stderr, "ezcx gen: %s: %v\n", name, err)
+			return 1
+		}
+		err = os.WriteFile(filepath.Join(*out, name), b, 0644)
+		if err != nil {
+			fmt.Fprintf(stderr, "ezcx gen: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "wrote %s\n", filepath.Join(*out, name))
+	}
+	return 0
+}
+
+// sysTypes maps system entity types to Go types.  System entities not
+// listed are strings.
+var sysTypes = map[string]string{
+	"@sys.any":              "any",
+	"@sys.number":           "float64",
+	"@sys.number-integer":   "float64",
+	"@sys.cardinal":         "float64",
+	"@sys.ordinal":          "float64",
+	"@sys.percentage":       "float64",
+	"@sys.date":             "sysentity.Date",
+	"@sys.time":             "sysentity.Time",
+	"@sys.date-time":        "sysentity.DateTime",
+	"@sys.unit-currency":    "sysentity.Money",
+	"@sys.person":           "sysentity.Person",
+	"@sys.phone-number":     "sysentity.Phone",
+	"@sys.location":         "sysentity.Location",
+	"@sys.duration":         "sysentity.Quantity",
+	"@sys.age":              "sysentity.Quantity",
+	"@sys.temperature":      "sysentity.Quantity",
+	"@sys.unit-length":      "sysentity.Quantity",
+	"@sys.unit-weight":      "sysentity.Quantity",
+	"@sys.date-period":      "map[string]any",
+	"@sys.time-period":      "map[string]any",
+	"@sys.date-time-period": "map[string]any",
+}
+
+type generator struct {
+	a     *agent.Agent
+	pkg   string
+	names map[string]bool
+	enums map[string]string // entity type display name -> Go type
+	sys   bool              // whether params_gen.go uses sysentity
+}
+
+func newGenerator(a *agent.Agent, pkg string) *generator {
+	g := &generator{a: a, pkg: pkg, names: make(map[string]bool), enums: make(map[string]string)}
+	for _, name := range sortedNames(a.EntityTypes) {
+		et := a.EntityTypes[name]
+		if et.Kind == "KIND_MAP" || et.Kind == "KIND_LIST" {
+			g.enums[name] = g.ident(exportedName(name))
+		}
+	}
+	return g
+}
+
+// ident returns a unique identifier based on name.
+func (g *generator) ident(name string) string {
+	id := name
+	for n := 2; g.names[id]; n++ {
+		id = fmt.Sprintf("%s%d", name, n)
+	}
+	g.names[id] = true
+	return id
+}
+
+func (g *generator) goType(entityType string, isList bool) string {
+	t, ok := sysTypes[entityType]
+	if strings.HasPrefix(t, "sysentity.") {
+		g.sys = true
+	}
+	if !ok {
+		t = "string"
+		if enum, isEnum := g.enums[entityType]; isEnum {
+			t = enum
+		}
+	}
+	if isList {
+		return "[]" + t
+	}
+	return t
+}
+
+type field struct {
+	name, typ, param string
+}
+
+func (g *generator) writeStruct(sb *strings.Builder, name, doc, source string, fields []field) {
+	fmt.Fprintf(sb, "// %s\ntype %s struct {\n", doc, name)
+	used := make(map[string]bool)
+	for _, f := range fields {
+		id := exportedName(f.name)
+		for n := 2; used[id]; n++ {
+			id = fmt.Sprintf("%s%d", exportedName(f.name), n)
+		}
+		used[id] = true
+		fmt.Fprintf(sb, "\t%s %s `cx:%q json:%q`\n", id, f.typ, f.param, f.param+",omitempty")
+	}
+	sb.WriteString("}\n\n")
+	fmt.Fprintf(sb, "// Decode sets p from the request's %s.\n", source)
+	fmt.Fprintf(sb, "func (p *%s) Decode(req *ezcx.WebhookRequest) error {\n", name)
+	if source == "form parameters" {
+		sb.WriteString("\treturn decodeParams(req.GetPageFormParameters(), p)\n}\n\n")
+	} else {
+		sb.WriteString("\treturn decodeParams(intentParams(req), p)\n}\n\n")
+	}
+}
+
+func (g *generator) params() []byte {
+	var sb strings.Builder
+
+	for _, name := range sortedNames(g.a.EntityTypes) {
+		enum, ok := g.enums[name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&sb, "// %s is a value of the custom entity type %q.\ntype %s string\n\n", enum, name, enum)
+		entities := g.a.EntityTypes[name].Entities[g.language()]
+		if len(entities) == 0 {
+			continue
+		}
+		sb.WriteString("const (\n")
+		used := make(map[string]bool)
+		for _, e := range entities {
+			id := enum + exportedName(e.Value)
+			for n := 2; used[id]; n++ {
+				id = fmt.Sprintf("%s%s%d", enum, exportedName(e.Value), n)
+			}
+			used[id] = true
+			fmt.Fprintf(&sb, "\t%s %s = %q\n", id, enum, e.Value)
+		}
+		sb.WriteString(")\n\n")
+	}
+
+	for _, fname := range sortedNames(g.a.Flows) {
+		flow := g.a.Flows[fname]
+		for _, pname := range sortedNames(flow.Pages) {
+			page := flow.Pages[pname]
+			if len(page.Parameters()) == 0 {
+				continue
+			}
+			var fields []field
+			for _, p := range page.Parameters() {
+				fields = append(fields, field{p.DisplayName, g.goType(p.EntityType, p.IsList), p.DisplayName})
+			}
+			name := g.ident(exportedName(pname) + "Form")
+			doc := fmt.Sprintf("%s holds the form parameters of page %q in flow %q.", name, pname, fname)
+			g.writeStruct(&sb, name, doc, "form parameters", fields)
+		}
+	}
+	for _, iname := range sortedNames(g.a.Intents) {
+		intent := g.a.Intents[iname]
+		if len(intent.Parameters) == 0 {
+			continue
+		}
+		var fields []field
+		for _, p := range intent.Parameters {
+			fields = append(fields, field{p.ID, g.goType(p.EntityType, p.IsList), p.ID})
+		}
+		name := g.ident(exportedName(iname) + "Params")
+		doc := fmt.Sprintf("%s holds the parameters of intent %q.", name, iname)
+		g.writeStruct(&sb, name, doc, "matched intent's parameters", fields)
+	}
+
+	sb.WriteString(`// decodeParams decodes parameters into the struct v via JSON.
+func decodeParams(params map[string]any, v any) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// intentParams returns the resolved values of the matched intent's
+// parameters.
+func intentParams(req *ezcx.WebhookRequest) map[string]any {
+	params := make(map[string]any)
+	for k, v := range req.GetIntentInfo().GetParameters() {
+		params[k] = v.GetResolvedValue().AsInterface()
+	}
+	return params
+}
+`)
+	var head strings.Builder
+	head.WriteString(genHeader)
+	fmt.Fprintf(&head, "package %s\n\nimport (\n\t\"encoding/json\"\n\n\t\"github.com/googlecloudplatform/ezcx\"\n", g.pkg)
+	if g.sys {
+		head.WriteString("\t\"github.com/googlecloudplatform/ezcx/sysentity\"\n")
+	}
+	head.WriteString(")\n\n")
+	return []byte(head.String() + sb.String())
+}
+
+// tagRefs returns each webhook tag in the agent with where it's used and
+// the unique constant and handler names generated for it.
+func (g *generator) tagRefs() []*scaffoldTag {
+	refs := make(map[string][]string)
+	for _, ref := range g.a.Fulfillments() {
+		if tag := ref.Fulfillment.Tag; tag != "" && ref.Fulfillment.Webhook != "" {
+			refs[tag] = append(refs[tag], ref.Location)
+		}
+	}
+	return scaffoldTags(refs)
+}
+
+func (g *generator) tags() []byte {
+	return tagConsts(g.pkg, g.tagRefs())
+}
+
+// tagConsts returns tags_gen.go, which is shared with ezcx new.
+func tagConsts(pkg string, tags []*scaffoldTag) []byte {
+	var sb strings.Builder
+	sb.WriteString(genHeader)
+	fmt.Fprintf(&sb, "package %s\n\n", pkg)
+	if len(tags) == 0 {
+		return []byte(sb.String())
+	}
+	sb.WriteString("// Webhook tags used by the agent.\nconst (\n")
+	for _, tag := range tags {
+		for _, loc := range tag.Locations {
+			fmt.Fprintf(&sb, "\t// %s\n", loc)
+		}
+		fmt.Fprintf(&sb, "\tTag%s = %q\n", tag.Test, tag.Tag)
+	}
+	sb.WriteString(")\n")
+	return []byte(sb.String())
+}
+
+// handlers returns the stub handlers.go that ezcx new also writes.
+func (g *generator) handlers() ([]byte, error) {
+	return render("handlers.go.tmpl", &scaffold{Package: g.pkg, Tags: g.tagRefs()})
+}
+
+func (g *generator) language() string {
+	if g.a.DefaultLanguageCode != "" {
+		return g.a.DefaultLanguageCode
+	}
+	return "en"
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
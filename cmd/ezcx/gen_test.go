@@ -0,0 +1,123 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/googlecloudplatform/ezcx/agent"
+)
+
+func TestGen(t *testing.T) {
+	dir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"gen", "-pkg", "coffee", "-o", dir, "-stubs", coffee}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d; stderr:\n%s", code, &stderr)
+	}
+	for name, wants := range map[string][]string{
+		"params_gen.go": {
+			"package coffee\n",
+			"type Size string",
+			`SizeLarge  Size = "large"`,
+			"type OrderForm struct {",
+			"Size     Size    `cx:\"size\" json:\"size,omitempty\"`",
+			"Quantity float64 `cx:\"quantity\" json:\"quantity,omitempty\"`",
+			"type OrderCoffeeParams struct {",
+		},
+		"tags_gen.go": {
+			`TagConfirmOrder = "confirm-order"`,
+			`TagStartOrder = "start-order"`,
+		},
+		"handlers.go": {
+			"tr.Handle(TagGoodbye, cxGoodbye)",
+			"func cxStartOrder(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {",
+		},
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range wants {
+			if !bytes.Contains(b, []byte(want)) {
+				t.Errorf("%s is missing %s:\n%s", name, want, b)
+			}
+		}
+	}
+
+	// An existing handlers.go is left alone.
+	handlers := filepath.Join(dir, "handlers.go")
+	err := os.WriteFile(handlers, []byte("package coffee\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code = run([]string{"gen", "-pkg", "coffee", "-o", dir, "-stubs", coffee}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d; stderr:\n%s", code, &stderr)
+	}
+	b, _ := os.ReadFile(handlers)
+	if string(b) != "package coffee\n" {
+		t.Errorf("handlers.go was overwritten:\n%s", b)
+	}
+
+	// Tags that map to the same Go name get distinct identifiers.
+	a := &agent.Agent{Flows: map[string]*agent.Flow{"Default Start Flow": {TransitionRoutes: []*agent.Route{
+		{Condition: "true", TriggerFulfillment: &agent.Fulfillment{Webhook: "w", Tag: "a-b"}},
+		{Condition: "true", TriggerFulfillment: &agent.Fulfillment{Webhook: "w", Tag: "a_b"}},
+	}}}}
+	g := newGenerator(a, "main")
+	stubs, err := g.handlers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range map[string][]byte{"tags_gen.go": g.tags(), "handlers.go": stubs} {
+		if _, err := format.Source(src); err != nil {
+			t.Errorf("%s: %v\n%s", name, err, src)
+		}
+	}
+	if b := g.tags(); !bytes.Contains(b, []byte(`TagAB = "a-b"`)) || !bytes.Contains(b, []byte(`TagAB2 = "a_b"`)) {
+		t.Errorf("tags_gen.go doesn't dedupe colliding tags:\n%s", b)
+	}
+	if !bytes.Contains(stubs, []byte("tr.Handle(TagAB2, cxAB2)")) {
+		t.Errorf("handlers.go doesn't dedupe colliding tags:\n%s", stubs)
+	}
+}
+
+func TestGenTypes(t *testing.T) {
+	a := &agent.Agent{EntityTypes: map[string]*agent.EntityType{"size": {Kind: "KIND_MAP"}}}
+	g := newGenerator(a, "main")
+	for _, tc := range []struct {
+		entityType string
+		isList     bool
+		want       string
+	}{
+		{"@sys.number", false, "float64"},
+		{"@sys.date", true, "[]sysentity.Date"},
+		{"@sys.geo-city", false, "string"},
+		{"size", false, "Size"},
+		{"@sys.unit-currency", false, "sysentity.Money"},
+	} {
+		if got := g.goType(tc.entityType, tc.isList); got != tc.want {
+			t.Errorf("goType(%q, %v) = %q, want %q", tc.entityType, tc.isList, got, tc.want)
+		}
+	}
+	if !g.sys {
+		t.Error("sysentity import not recorded")
+	}
+}
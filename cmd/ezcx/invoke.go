@@ -0,0 +1,326 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/ezcxtest"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// listFlag collects repeated string flags.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+const invokeUsage = `Usage: ezcx invoke [flags] <url>
+
+Invoke POSTs a WebhookRequest to a running ezcx server and prints the
+response's messages, parameter changes and transition.  The request is
+built from the flags, on top of -f if given; without -f it starts from
+ezcxtest.NewRequest's defaults.  Parameter values are parsed as JSON if
+possible, e.g. -param count=2 -param 'date={"year":2022,"month":10,"day":7}',
+and as strings otherwise.
+
+Flags:
+`
+
+func runInvoke(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("invoke", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	file := fs.String("f", "", "read the WebhookRequest from a protojson `file`")
+	tag := fs.String("tag", "", "fulfillment tag")
+	text := fs.String("text", "", "user text input")
+	event := fs.String("event", "", "trigger event")
+	lang := fs.String("lang", "", "language code")
+	session := fs.String("session", "", "session ID or full session name")
+	page := fs.String("page", "", "current page display name")
+	var params, payload, headers listFlag
+	fs.Var(&params, "param", "session parameter `name=value`; may be repeated")
+	fs.Var(&payload, "payload", "payload field `name=value`; may be repeated")
+	fs.Var(&headers, "H", "extra HTTP `header`, e.g. 'Authorization: Bearer ...'; may be repeated")
+	token := fs.String("token", "", "bearer `token` for the Authorization header")
+	timeout := fs.Duration("timeout", 30*time.Second, "request timeout")
+	raw := fs.Bool("raw", false, "print the raw JSON response")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), invokeUsage)
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	var req *ezcx.WebhookRequest
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err == nil {
+			req, err = ezcx.WebhookRequestFromReader(f)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "ezcx invoke: %v\n", err)
+			return 1
+		}
+	} else {
+		req = ezcxtest.NewRequest().MustBuild()
+	}
+	err = override(req, *tag, *text, *event, *lang, *session, *page, params, payload)
+	if err != nil {
+		fmt.Fprintf(stderr, "ezcx invoke: %v\n", err)
+		return 2
+	}
+
+	var body bytes.Buffer
+	err = req.WriteRequest(&body)
+	if err != nil {
+		fmt.Fprintf(stderr, "ezcx invoke: %v\n", err)
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	hr, err := http.NewRequestWithContext(ctx, http.MethodPost, fs.Arg(0), &body)
+	if err != nil {
+		fmt.Fprintf(stderr, "ezcx invoke: %v\n", err)
+		return 2
+	}
+	hr.Header.Set("Content-Type", "application/json")
+	if *token != "" {
+		hr.Header.Set("Authorization", "Bearer "+*token)
+	}
+	for _, h := range headers {
+		k, v, ok := strings.Cut(h, ":")
+		if !ok {
+			fmt.Fprintf(stderr, "ezcx invoke: bad header %q\n", h)
+			return 2
+		}
+		hr.Header.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	resp, err := http.DefaultClient.Do(hr)
+	if err != nil {
+		fmt.Fprintf(stderr, "ezcx invoke: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Fprintf(stderr, "ezcx invoke: %v\n", err)
+		return 1
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(stderr, "ezcx invoke: %s\n%s\n", resp.Status, b)
+		return 1
+	}
+	if *raw {
+		stdout.Write(b)
+		return 0
+	}
+	res := ezcx.NewWebhookResponse()
+	err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, &res.WebhookResponse)
+	if err != nil {
+		fmt.Fprintf(stderr, "ezcx invoke: decoding the response: %v\n%s\n", err, b)
+		return 1
+	}
+	printResponse(stdout, req, res)
+	return 0
+}
+
+// override applies the flags to req.
+func override(req *ezcx.WebhookRequest, tag, text, event, lang, session, page string, params, payload []string) error {
+	if tag != "" {
+		req.FulfillmentInfo = &cx.WebhookRequest_FulfillmentInfo{Tag: tag}
+	}
+	switch {
+	case text != "" && event != "":
+		return errors.New("-text and -event are mutually exclusive")
+	case text != "":
+		req.Query = &cx.WebhookRequest_Text{Text: text}
+	case event != "":
+		req.Query = &cx.WebhookRequest_TriggerEvent{TriggerEvent: event}
+	}
+	if lang != "" {
+		req.LanguageCode = lang
+	}
+	if req.SessionInfo == nil {
+		req.SessionInfo = new(cx.SessionInfo)
+	}
+	if session != "" {
+		if strings.Contains(session, "/") {
+			req.SessionInfo.Session = session
+		} else {
+			name := req.SessionInfo.Session
+			req.SessionInfo.Session = name[:strings.LastIndex(name, "/")+1] + session
+		}
+	}
+	if page != "" {
+		if req.PageInfo == nil {
+			req.PageInfo = new(cx.PageInfo)
+		}
+		req.PageInfo.DisplayName = page
+	}
+	if len(params) > 0 && req.SessionInfo.Parameters == nil {
+		req.SessionInfo.Parameters = make(map[string]*structpb.Value)
+	}
+	for _, p := range params {
+		k, v, err := parseAssignment(p)
+		if err != nil {
+			return err
+		}
+		req.SessionInfo.Parameters[k] = v
+	}
+	if len(payload) > 0 && req.Payload == nil {
+		req.Payload = &structpb.Struct{Fields: make(map[string]*structpb.Value)}
+	}
+	for _, p := range payload {
+		k, v, err := parseAssignment(p)
+		if err != nil {
+			return err
+		}
+		req.Payload.Fields[k] = v
+	}
+	return nil
+}
+
+// parseAssignment parses name=value, where value is JSON or a string.
+func parseAssignment(s string) (string, *structpb.Value, error) {
+	k, raw, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return "", nil, fmt.Errorf("bad assignment %q; want name=value", s)
+	}
+	var v any
+	err := json.Unmarshal([]byte(raw), &v)
+	if err != nil {
+		v = raw
+	}
+	pv, err := structpb.NewValue(v)
+	if err != nil {
+		return "", nil, err
+	}
+	return k, pv, nil
+}
+
+func printResponse(w io.Writer, req *ezcx.WebhookRequest, res *ezcx.WebhookResponse) {
+	msgs := res.GetFulfillmentResponse().GetMessages()
+	fmt.Fprintln(w, "Messages:")
+	if len(msgs) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, m := range msgs {
+		switch {
+		case m.GetText() != nil:
+			for _, t := range m.GetText().GetText() {
+				fmt.Fprintf(w, "  %s\n", t)
+			}
+		case m.GetPayload() != nil:
+			fmt.Fprintf(w, "  [payload] %s\n", compactJSON(m.GetPayload().AsMap()))
+		case m.GetOutputAudioText() != nil:
+			fmt.Fprintf(w, "  [audio] %s%s\n", m.GetOutputAudioText().GetText(), m.GetOutputAudioText().GetSsml())
+		case m.GetTelephonyTransferCall() != nil:
+			fmt.Fprintf(w, "  [transfer] %s\n", m.GetTelephonyTransferCall().GetPhoneNumber())
+		default:
+			fmt.Fprintf(w, "  [message] %s\n", protojson.Format(m))
+		}
+	}
+
+	before := req.GetSessionInfo().GetParameters()
+	after := res.GetSessionInfo().GetParameters()
+	names := make([]string, 0, len(after))
+	for k := range after {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var changes []string
+	for _, k := range names {
+		v := after[k]
+		old, existed := before[k]
+		switch {
+		case v.GetKind() == nil, isNull(v):
+			changes = append(changes, fmt.Sprintf("  - %s", k))
+		case !existed:
+			changes = append(changes, fmt.Sprintf("  + %s = %s", k, compactJSON(v.AsInterface())))
+		case compactJSON(old.AsInterface()) != compactJSON(v.AsInterface()):
+			changes = append(changes, fmt.Sprintf("  ~ %s = %s (was %s)", k, compactJSON(v.AsInterface()), compactJSON(old.AsInterface())))
+		}
+	}
+	if len(changes) > 0 {
+		fmt.Fprintln(w, "Parameters:")
+		for _, c := range changes {
+			fmt.Fprintln(w, c)
+		}
+	}
+
+	if form := res.GetPageInfo().GetFormInfo().GetParameterInfo(); len(form) > 0 {
+		fmt.Fprintln(w, "Form:")
+		for _, p := range form {
+			fmt.Fprintf(w, "  %s: %s", p.GetDisplayName(), p.GetState())
+			if p.GetValue() != nil {
+				fmt.Fprintf(w, " = %s", compactJSON(p.GetValue().AsInterface()))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	switch {
+	case res.GetTargetPage() != "":
+		fmt.Fprintf(w, "Transition: page %s\n", res.GetTargetPage())
+	case res.GetTargetFlow() != "":
+		fmt.Fprintf(w, "Transition: flow %s\n", res.GetTargetFlow())
+	}
+	if p := res.GetPayload(); p != nil && len(p.GetFields()) > 0 {
+		fmt.Fprintf(w, "Payload: %s\n", compactJSON(p.AsMap()))
+	}
+}
+
+func isNull(v *structpb.Value) bool {
+	_, ok := v.GetKind().(*structpb.Value_NullValue)
+	return ok
+}
+
+func compactJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
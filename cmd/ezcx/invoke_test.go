@@ -0,0 +1,112 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/ezcxtest"
+)
+
+func cxOrder(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+	size, _ := req.GetSessionParameter("size")
+	count, _ := req.GetSessionParameter("count")
+	res.AddTextResponse(fmt.Sprintf("%s: %v %v (%s)", req.GetFulfillmentInfo().GetTag(), count, size, req.GetText()))
+	err := res.AddSessionParameters(map[string]any{"size": nil, "count": 3, "order_id": "A1"})
+	if err != nil {
+		return err
+	}
+	res.Transition = &cx.WebhookResponse_TargetPage{TargetPage: "projects/p/locations/l/agents/a/flows/f/pages/confirm"}
+	return nil
+}
+
+func TestInvoke(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		ezcx.HandlerFunc(cxOrder).ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"invoke", "-tag", "order", "-text", "a large one", "-param", "size=large", "-param", "count=2", "-token", "secret", srv.URL}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d; stderr:\n%s", code, &stderr)
+	}
+	want := `Messages:
+  order: 2 large (a large one)
+Parameters:
+  ~ count = 3 (was 2)
+  + order_id = "A1"
+  - size
+Transition: page projects/p/locations/l/agents/a/flows/f/pages/confirm
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+}
+
+func TestInvokeFile(t *testing.T) {
+	srv := httptest.NewServer(ezcx.HandlerFunc(cxOrder))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	err := ezcxtest.NewRequest().Tag("from-file").SessionParam("size", "small").MustBuild().WriteRequest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "req.json")
+	err = os.WriteFile(name, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"invoke", "-f", name, "-text", "hi", "-raw", srv.URL}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d; stderr:\n%s", code, &stderr)
+	}
+	if !strings.Contains(stdout.String(), `from-file: <nil> small (hi)`) {
+		t.Errorf("raw output:\n%s", &stdout)
+	}
+}
+
+func TestInvokeErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"invoke", srv.URL}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "401") {
+		t.Errorf("exit code %d; stderr:\n%s", code, &stderr)
+	}
+	if code := run([]string{"invoke", "-text", "a", "-event", "b", srv.URL}, &stdout, &stderr); code != 2 {
+		t.Errorf("-text with -event: exit code %d, want 2", code)
+	}
+	if code := run([]string{"invoke", "-param", "novalue", srv.URL}, &stdout, &stderr); code != 2 {
+		t.Errorf("bad -param: exit code %d, want 2", code)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/agent"
+	"github.com/googlecloudplatform/ezcx/lint"
+)
+
+// routeFlags collects -route flags of the form pattern[=tag,tag...].
+type routeFlags map[string][]string
+
+func (r routeFlags) String() string {
+	return fmt.Sprint(map[string][]string(r))
+}
+
+func (r routeFlags) Set(v string) error {
+	pattern, tags, hasTags := strings.Cut(v, "=")
+	if pattern == "" {
+		return errors.New("empty pattern")
+	}
+	if !hasTags {
+		r[pattern] = nil
+		return nil
+	}
+	r[pattern] = append(r[pattern], strings.Split(tags, ",")...)
+	return nil
+}
+
+const lintUsage = `Usage: ezcx lint [flags] <agent export zip or directory>
+
+Lint checks every webhook reference in a Dialogflow CX agent export against
+a route table and reports unhandled tags, unused handlers and webhooks
+pointing at unknown paths.  The route table comes from -route flags and/or
+a -routes JSON file mapping patterns to tags, e.g.
+
+	{"/cx": ["welcome", "order"], "/legacy": null}
+
+A null (or omitted) tag list means the handler accepts any tag.  To lint
+against a Server's routes in a test, use lint.Check with
+(*ezcx.Server).Routes instead.
+
+Flags:
+`
+
+func runLint(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	routes := make(routeFlags)
+	fs.Var(routes, "route", "route `pattern[=tag,tag...]`; may be repeated")
+	routesFile := fs.String("routes", "", "JSON `file` mapping route patterns to tags")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), lintUsage)
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if *routesFile != "" {
+		b, err := os.ReadFile(*routesFile)
+		if err == nil {
+			var m map[string][]string
+			err = json.Unmarshal(b, &m)
+			for k, v := range m {
+				routes[k] = append(routes[k], v...)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "ezcx lint: %v\n", err)
+			return 1
+		}
+	}
+	if len(routes) == 0 {
+		fmt.Fprintln(stderr, "ezcx lint: no routes; use -route or -routes")
+		return 2
+	}
+
+	a, err := agent.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "ezcx lint: %v\n", err)
+		return 1
+	}
+	table := make([]ezcx.Route, 0, len(routes))
+	for pattern, tags := range routes {
+		table = append(table, ezcx.Route{Pattern: pattern, Tags: tags})
+	}
+	sort.Slice(table, func(i, j int) bool { return table[i].Pattern < table[j].Pattern })
+
+	issues := lint.Check(a, table)
+	for _, i := range issues {
+		fmt.Fprintln(stdout, i)
+	}
+	if len(issues) > 0 {
+		fmt.Fprintf(stdout, "%d issue(s)\n", len(issues))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const coffee = "../../agent/testdata/coffee"
+
+func TestLint(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"lint", "-route", "/order=start-order,confirm-order,goodbye", coffee}, &stdout, &stderr)
+	if code != 0 {
+		t.Errorf("exit code %d; stdout:\n%s\nstderr:\n%s", code, &stdout, &stderr)
+	}
+
+	routes := filepath.Join(t.TempDir(), "routes.json")
+	err := os.WriteFile(routes, []byte(`{"/order": ["start-order", "confirm-order", "good-bye"]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	code = run([]string{"lint", "-routes", routes, coffee}, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("exit code %d, want 1", code)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, `unhandled tag: tag "goodbye"`) || !strings.Contains(out, `unused handler: tag "good-bye"`) || !strings.HasSuffix(out, "2 issue(s)\n") {
+		t.Errorf("output:\n%s", out)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run(nil, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), "lint") {
+		t.Errorf("run() = %d, stderr:\n%s", code, &stderr)
+	}
+	if code := run([]string{"bogus"}, &stdout, &stderr); code != 2 {
+		t.Errorf("run(bogus) = %d, want 2", code)
+	}
+	stdout.Reset()
+	if code := run([]string{"help", "lint"}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "-route") {
+		t.Errorf("help lint = %d, stdout:\n%s", code, &stdout)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/googlecloudplatform/ezcx/agent"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+const newUsage = `Usage: ezcx new [flags] <name>
+
+New generates a webhook service module in the directory <name>: main.go
+using ezcx.NewServer and a TagRouter, the tag constants and stub handlers
+that ezcx gen writes, a test per tag, config.json, a Dockerfile and
+cloudbuild.yaml.  Tags come from -tags and/or
+every webhook tag in the agent export given with -agent.  The templates are
+embedded, so no network access is needed; run go mod tidy in the new
+module to fetch ezcx.
+
+Flags:
+`
+
+// scaffold is the data passed to the templates.
+type scaffold struct {
+	Name    string
+	Module  string
+	Package string
+	Tags    []*scaffoldTag
+}
+
+type scaffoldTag struct {
+	Tag       string
+	Func      string
+	Test      string
+	Locations []string
+}
+
+func runNew(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("new", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	module := fs.String("module", "", "module `path`; defaults to the name")
+	tags := fs.String("tags", "", "comma-separated webhook `tags`")
+	export := fs.String("agent", "", "agent export `zip or directory` to read tags from")
+	dir := fs.String("dir", "", "output `directory`; defaults to the name")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), newUsage)
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	s := &scaffold{Name: fs.Arg(0), Module: *module, Package: "main"}
+	if s.Module == "" {
+		s.Module = s.Name
+	}
+	if *dir == "" {
+		*dir = s.Name
+	}
+
+	locations := make(map[string][]string)
+	for _, tag := range strings.Split(*tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			if _, ok := locations[tag]; !ok {
+				locations[tag] = nil
+			}
+		}
+	}
+	if *export != "" {
+		a, err := agent.Load(*export)
+		if err != nil {
+			fmt.Fprintf(stderr, "ezcx new: %v\n", err)
+			return 1
+		}
+		for _, ref := range a.Fulfillments() {
+			if tag := ref.Fulfillment.Tag; tag != "" && ref.Fulfillment.Webhook != "" {
+				locations[tag] = append(locations[tag], ref.Location)
+			}
+		}
+	}
+	if len(locations) == 0 {
+		locations["welcome"] = nil
+	}
+	s.Tags = scaffoldTags(locations)
+
+	err = s.write(*dir)
+	if err != nil {
+		fmt.Fprintf(stderr, "ezcx new: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "created %s with %d handler(s); run go mod tidy in %s to get started\n", s.Module, len(s.Tags), *dir)
+	return 0
+}
+
+func scaffoldTags(locations map[string][]string) []*scaffoldTag {
+	names := make([]string, 0, len(locations))
+	for tag := range locations {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+	used := make(map[string]bool)
+	var tags []*scaffoldTag
+	for _, tag := range names {
+		id := exportedName(tag)
+		for n := 2; used[id]; n++ {
+			id = fmt.Sprintf("%s%d", exportedName(tag), n)
+		}
+		used[id] = true
+		tags = append(tags, &scaffoldTag{Tag: tag, Func: "cx" + id, Test: id, Locations: locations[tag]})
+	}
+	return tags
+}
+
+// exportedName turns a tag like "start-order" into "StartOrder".
+func exportedName(tag string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	name := sb.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "Tag" + name
+	}
+	return name
+}
+
+// write renders every template into dir, which must not exist or be empty.
+func (s *scaffold) write(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s already exists and isn't empty", dir)
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	names, err := templates.ReadDir("templates")
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{"tags_gen.go": tagConsts(s.Package, s.Tags)}
+	for _, e := range names {
+		name := strings.TrimSuffix(e.Name(), ".tmpl")
+		files[name], err = render(e.Name(), s)
+		if err != nil {
+			return err
+		}
+	}
+	for name, b := range files {
+		if strings.HasSuffix(name, ".go") {
+			b, err = format.Source(b)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		err = os.WriteFile(filepath.Join(dir, name), b, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render executes the template named name with s.
+func render(name string, s *scaffold) ([]byte, error) {
+	tmpl, err := template.ParseFS(templates, path.Join("templates", name))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, s)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "coffee")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"new", "-dir", dir, "-module", "example.com/coffee", "-agent", coffee, "-tags", "extra", "coffee"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d; stderr:\n%s", code, &stderr)
+	}
+	for _, name := range []string{"go.mod", "main.go", "handlers.go", "handlers_test.go", "config.json", "Dockerfile", "cloudbuild.yaml", "README.md"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "handlers.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"tr.Handle(TagConfirmOrder, cxConfirmOrder)", "tr.Handle(TagExtra, cxExtra)", "tr.Handle(TagGoodbye, cxGoodbye)", "tr.Handle(TagStartOrder, cxStartOrder)"} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("handlers.go is missing %s", want)
+		}
+	}
+	b, err = os.ReadFile(filepath.Join(dir, "tags_gen.go"))
+	if err != nil || !strings.Contains(strings.Join(strings.Fields(string(b)), " "), `TagExtra = "extra"`) {
+		t.Errorf("tags_gen.go = %s, %v", b, err)
+	}
+	b, err = os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil || !strings.HasPrefix(string(b), "module example.com/coffee\n") {
+		t.Errorf("go.mod = %q, %v", b, err)
+	}
+
+	code = run([]string{"new", "-dir", dir, "coffee"}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "isn't empty") {
+		t.Errorf("overwriting: exit code %d; stderr:\n%s", code, &stderr)
+	}
+}
+
+// TestNewGen checks that ezcx gen writes the same tags_gen.go and stub
+// handlers.go as ezcx new, so it can regenerate them in a new project.
+func TestNewGen(t *testing.T) {
+	dir := t.TempDir()
+	scaffolded, generated := filepath.Join(dir, "new"), filepath.Join(dir, "gen")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"new", "-dir", scaffolded, "-agent", coffee, "coffee"}, &stdout, &stderr)
+	if code == 0 {
+		code = run([]string{"gen", "-o", generated, "-stubs", coffee}, &stdout, &stderr)
+	}
+	if code != 0 {
+		t.Fatalf("exit code %d; stderr:\n%s", code, &stderr)
+	}
+	for _, name := range []string{"tags_gen.go", "handlers.go"} {
+		want, err := os.ReadFile(filepath.Join(scaffolded, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(generated, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("gen %s:\n%s\nnew %s:\n%s", name, got, name, want)
+		}
+	}
+}
+
+func TestExportedName(t *testing.T) {
+	for tag, want := range map[string]string{
+		"start-order":   "StartOrder",
+		"get_balance":   "GetBalance",
+		"v2.lookup":     "V2Lookup",
+		"2fa":           "Tag2fa",
+		"--":            "Tag",
+		"already Camel": "AlreadyCamel",
+	} {
+		if got := exportedName(tag); got != want {
+			t.Errorf("exportedName(%q) = %q, want %q", tag, got, want)
+		}
+	}
+	tags := scaffoldTags(map[string][]string{"a-b": nil, "a_b": nil})
+	if tags[0].Func != "cxAB" || tags[1].Func != "cxAB2" {
+		t.Errorf("funcs = %s, %s", tags[0].Func, tags[1].Func)
+	}
+}
@@ -23,7 +23,9 @@ import (
 type contextKey int
 
 const (
+	// Logger is the context key for the request scoped *slog.Logger.
 	Logger contextKey = iota
+	sessionStoreKey
 )
 
 func anyToProto(value any) (*structpb.Value, error) {
@@ -0,0 +1,140 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emulator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Conditions support a subset of the CX condition syntax: true and false,
+// comparisons (=, !=, <, <=, >, >=) of $session.params.X, $page.params.X
+// (including $page.params.status) and $intent.params.X against literals
+// or null, and AND / OR without parentheses.
+var comparisonRe = regexp.MustCompile(`^(\$[\w.-]+)\s*(!=|<=|>=|=|<|>)\s*(.+)$`)
+
+// eval evaluates a route condition against the session.
+func (s *Session) eval(cond string) (bool, error) {
+	for _, or := range strings.Split(cond, " OR ") {
+		all := true
+		for _, and := range strings.Split(or, " AND ") {
+			ok, err := s.evalComparison(strings.TrimSpace(and))
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Session) evalComparison(expr string) (bool, error) {
+	switch strings.ToLower(expr) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	m := comparisonRe.FindStringSubmatch(expr)
+	if m == nil {
+		return false, fmt.Errorf("emulator: unsupported condition %q", expr)
+	}
+	got, err := s.reference(m[1])
+	if err != nil {
+		return false, err
+	}
+	want, err := literal(strings.TrimSpace(m[3]))
+	if err != nil {
+		return false, err
+	}
+	switch m[2] {
+	case "=":
+		return equal(got, want), nil
+	case "!=":
+		return !equal(got, want), nil
+	}
+	g, gok := got.(float64)
+	w, wok := want.(float64)
+	if !gok || !wok {
+		return false, nil
+	}
+	switch m[2] {
+	case "<":
+		return g < w, nil
+	case "<=":
+		return g <= w, nil
+	case ">":
+		return g > w, nil
+	default:
+		return g >= w, nil
+	}
+}
+
+// reference returns the value of a $session, $page or $intent reference,
+// or nil if it isn't set.
+func (s *Session) reference(ref string) (any, error) {
+	switch {
+	case ref == "$page.params.status":
+		if s.page != nil && s.collecting() == nil {
+			return "FINAL", nil
+		}
+		return nil, nil
+	case strings.HasPrefix(ref, "$session.params."):
+		return s.params[strings.TrimPrefix(ref, "$session.params.")], nil
+	case strings.HasPrefix(ref, "$page.params."):
+		name := strings.TrimPrefix(ref, "$page.params.")
+		for _, fs := range s.form {
+			if fs.param.DisplayName == name {
+				return fs.value, nil
+			}
+		}
+		return nil, nil
+	case strings.HasPrefix(ref, "$intent.params."):
+		return s.params[strings.TrimPrefix(ref, "$intent.params.")], nil
+	}
+	return nil, fmt.Errorf("emulator: unsupported reference %s", ref)
+}
+
+func literal(v string) (any, error) {
+	switch {
+	case v == "null":
+		return nil, nil
+	case v == "true" || v == "false":
+		return v == "true", nil
+	case strings.HasPrefix(v, `"`):
+		return strconv.Unquote(v)
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		// Unquoted strings are allowed e.g. $session.params.size = large.
+		return v, nil
+	}
+	return f, nil
+}
+
+func equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
@@ -0,0 +1,653 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package emulator runs conversations against an exported Dialogflow CX
+// agent locally, calling ezcx handlers in-process wherever the agent calls
+// a webhook.
+//
+// The emulator covers enough of the CX runtime for CI: text and event
+// input, intent matching by training phrase lookup (with annotated
+// parameters), form filling with custom and common system entities, entry
+// fulfillments, condition routes, event handlers, route groups and webhook
+// calls with CX's response merge semantics.  It's not a substitute for the
+// NLU; an utterance only matches an intent if it matches one of the
+// intent's training phrases, ignoring case and punctuation.
+package emulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/google/uuid"
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/agent"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Special pages; exports may use either the ID or the display name.
+const (
+	StartPage    = "START_PAGE"
+	EndSession   = "END_SESSION"
+	EndFlow      = "END_FLOW"
+	CurrentPage  = "CURRENT_PAGE"
+	PreviousPage = "PREVIOUS_PAGE"
+)
+
+var specialPages = map[string]string{
+	"Start Page":    StartPage,
+	"End Session":   EndSession,
+	"End Flow":      EndFlow,
+	"Current Page":  CurrentPage,
+	"Previous Page": PreviousPage,
+}
+
+const maxTransitions = 32
+
+const defaultLanguageCode = "en"
+
+const (
+	empty   = cx.PageInfo_FormInfo_ParameterInfo_EMPTY
+	invalid = cx.PageInfo_FormInfo_ParameterInfo_INVALID
+	filled  = cx.PageInfo_FormInfo_ParameterInfo_FILLED
+)
+
+var (
+	ErrSessionEnded = errors.New("emulator: the session has ended")
+	ErrNoHandler    = errors.New("emulator: no handler")
+)
+
+// Emulator runs sessions against an agent.
+type Emulator struct {
+	Agent *agent.Agent
+	// Handlers maps webhook URL paths (e.g. "/order") or fulfillment tags
+	// to handlers.  The webhook's path is looked up first, then the tag.
+	Handlers map[string]ezcx.HandlerFunc
+	// LanguageCode defaults to the agent's default language.
+	LanguageCode string
+}
+
+func New(a *agent.Agent, handlers map[string]ezcx.HandlerFunc) *Emulator {
+	return &Emulator{Agent: a, Handlers: handlers}
+}
+
+// NewSession starts a session on the start page of the agent's start flow
+// with the given session parameters preset.
+func (e *Emulator) NewSession(params map[string]any) (*Session, error) {
+	flow, ok := e.Agent.Flows[e.Agent.StartFlow]
+	if !ok {
+		return nil, fmt.Errorf("emulator: start flow %q not found", e.Agent.StartFlow)
+	}
+	s := &Session{
+		ID:     uuid.New().String(),
+		e:      e,
+		params: make(map[string]any),
+		flow:   flow,
+	}
+	for k, v := range params {
+		s.params[k] = v
+	}
+	return s, nil
+}
+
+func (e *Emulator) language() string {
+	if e.LanguageCode != "" {
+		return e.LanguageCode
+	}
+	if e.Agent.DefaultLanguageCode != "" {
+		return e.Agent.DefaultLanguageCode
+	}
+	return defaultLanguageCode
+}
+
+type location struct {
+	flow *agent.Flow
+	page *agent.Page
+}
+
+type formState struct {
+	param         *agent.FormParameter
+	value         any
+	state         cx.PageInfo_FormInfo_ParameterInfo_ParameterState
+	justCollected bool
+	noMatches     int
+}
+
+// Session is a single conversation.  Sessions aren't safe for concurrent
+// use.
+type Session struct {
+	ID string
+
+	e       *Emulator
+	params  map[string]any
+	flow    *agent.Flow
+	page    *agent.Page // nil is the flow's start page
+	history []location
+	// calls holds the pages that transitioned into a flow, innermost
+	// last; END_FLOW returns to the top one.
+	calls []location
+	form  []*formState
+	ended bool
+}
+
+// WebhookCall is a webhook call made during a turn.
+type WebhookCall struct {
+	Request  *ezcx.WebhookRequest
+	Response *ezcx.WebhookResponse
+	Err      error
+}
+
+// Response is the result of a turn.
+type Response struct {
+	// Messages are the text messages, in order.
+	Messages []string
+	Payloads []map[string]any
+	// Intent is the display name of the matched intent, if any.
+	Intent string
+	Flow   string
+	Page   string
+	Params map[string]any
+	// Webhooks are the webhook calls made during the turn.
+	Webhooks []*WebhookCall
+	Ended    bool
+}
+
+// Params returns a copy of the session parameters.
+func (s *Session) Params() map[string]any {
+	m := make(map[string]any, len(s.params))
+	for k, v := range s.params {
+		m[k] = v
+	}
+	return m
+}
+
+// Page returns the display names of the current flow and page.
+func (s *Session) Page() (flow, page string) {
+	return s.flow.DisplayName, pageName(s.page)
+}
+
+func (s *Session) Ended() bool {
+	return s.ended
+}
+
+// Text sends the user's text input.
+func (s *Session) Text(ctx context.Context, text string) (*Response, error) {
+	if s.ended {
+		return nil, ErrSessionEnded
+	}
+	t := &turn{s: s, ctx: ctx, text: text}
+	err := t.handleText()
+	if err != nil {
+		return nil, err
+	}
+	return t.response(), nil
+}
+
+// Event triggers the named event e.g. "sys.no-input-default".
+func (s *Session) Event(ctx context.Context, event string) (*Response, error) {
+	if s.ended {
+		return nil, ErrSessionEnded
+	}
+	t := &turn{s: s, ctx: ctx, event: event}
+	handled, err := t.handleEvent(event)
+	if err == nil && !handled {
+		err = fmt.Errorf("emulator: no handler for event %q", event)
+	}
+	if err == nil {
+		err = t.settle()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t.response(), nil
+}
+
+// turn holds the state of a single turn.
+type turn struct {
+	s     *Session
+	ctx   context.Context
+	text  string
+	event string
+
+	intent       *agent.Intent
+	intentParams map[string]matchedParam
+
+	messages   []string
+	payloads   []map[string]any
+	webhooks   []*WebhookCall
+	entered    bool
+	returned   bool
+	reprompted bool
+}
+
+func (t *turn) response() *Response {
+	return &Response{
+		Messages: t.messages,
+		Payloads: t.payloads,
+		Intent:   intentName(t.intent),
+		Flow:     t.s.flow.DisplayName,
+		Page:     pageName(t.s.page),
+		Params:   t.s.Params(),
+		Webhooks: t.webhooks,
+		Ended:    t.s.ended,
+	}
+}
+
+func (t *turn) handleText() error {
+	route, intent, params := t.s.matchIntent(t.text)
+	switch {
+	case route != nil:
+		t.intent = intent
+		t.intentParams = params
+		for k, p := range params {
+			t.s.params[k] = p.resolved
+		}
+		err := t.takeRoute(route)
+		if err != nil {
+			return err
+		}
+	case t.fillForm():
+	default:
+		_, err := t.noMatch()
+		if err != nil {
+			return err
+		}
+	}
+	return t.settle()
+}
+
+// fillForm fills the form parameter being collected from the user's input.
+func (t *turn) fillForm() bool {
+	fs := t.s.collecting()
+	if fs == nil {
+		return false
+	}
+	v, ok := t.s.resolve(fs.param.EntityType, t.text)
+	if !ok {
+		return false
+	}
+	fs.value = v
+	fs.state = filled
+	fs.justCollected = true
+	t.s.params[fs.param.DisplayName] = v
+	return true
+}
+
+// noMatch handles sys.no-match-N and sys.no-match-default, starting with
+// the reprompt handlers of the form parameter being collected.
+func (t *turn) noMatch() (bool, error) {
+	fs := t.s.collecting()
+	n := 1
+	if fs != nil {
+		fs.noMatches++
+		n = fs.noMatches
+	}
+	events := []string{fmt.Sprintf("sys.no-match-%d", n), "sys.no-match-default"}
+	if fs != nil && fs.param.FillBehavior != nil {
+		for _, event := range events {
+			h := findHandler(fs.param.FillBehavior.RepromptEventHandlers, event)
+			if h != nil {
+				t.reprompted = true
+				return true, t.takeHandler(h)
+			}
+		}
+	}
+	for _, event := range events {
+		handled, err := t.handleEvent(event)
+		if handled || err != nil {
+			t.reprompted = handled && fs != nil
+			return handled, err
+		}
+	}
+	return false, nil
+}
+
+// handleEvent runs the page's, then the flow's, handler for event.
+func (t *turn) handleEvent(event string) (bool, error) {
+	var h *agent.EventHandler
+	if t.s.page != nil {
+		h = findHandler(t.s.page.EventHandlers, event)
+	}
+	if h == nil {
+		h = findHandler(t.s.flow.EventHandlers, event)
+	}
+	if h == nil {
+		return false, nil
+	}
+	return true, t.takeHandler(h)
+}
+
+func findHandler(hs []*agent.EventHandler, event string) *agent.EventHandler {
+	for _, h := range hs {
+		if h.Event == event {
+			return h
+		}
+	}
+	return nil
+}
+
+func (t *turn) takeHandler(h *agent.EventHandler) error {
+	moved, err := t.fulfill(h.TriggerFulfillment)
+	if err != nil || moved {
+		return err
+	}
+	return t.s.transition(t, h.TargetPage, h.TargetFlow)
+}
+
+func (t *turn) takeRoute(r *agent.Route) error {
+	moved, err := t.fulfill(r.TriggerFulfillment)
+	if err != nil || moved {
+		return err
+	}
+	return t.s.transition(t, r.TargetPage, r.TargetFlow)
+}
+
+// settle runs entry fulfillments, form prompts and condition routes until
+// the session is waiting for input.
+func (t *turn) settle() error {
+	for i := 0; i < maxTransitions; i++ {
+		if t.s.ended {
+			return nil
+		}
+		if t.entered {
+			t.entered = false
+			t.s.initForm()
+			if t.s.page != nil {
+				moved, err := t.fulfill(t.s.page.EntryFulfillment)
+				if err != nil {
+					return err
+				}
+				if moved {
+					continue
+				}
+			}
+		}
+		if fs := t.s.collecting(); fs != nil {
+			if !t.reprompted && fs.param.FillBehavior != nil {
+				_, err := t.fulfill(fs.param.FillBehavior.InitialPromptFulfillment)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		route, err := t.s.matchCondition()
+		if err != nil || route == nil {
+			return err
+		}
+		t.entered, t.returned = false, false
+		err = t.takeRoute(route)
+		if err != nil {
+			return err
+		}
+		if !t.entered && !t.returned && !t.s.ended && t.s.collecting() == nil {
+			// The route didn't transition; stop rather than take it again.
+			return nil
+		}
+	}
+	return fmt.Errorf("emulator: more than %d transitions in one turn", maxTransitions)
+}
+
+// fulfill runs a fulfillment and reports whether a webhook transitioned
+// the session.
+func (t *turn) fulfill(f *agent.Fulfillment) (bool, error) {
+	if f == nil {
+		return false, nil
+	}
+	lang := t.s.e.language()
+	for _, m := range f.Messages {
+		if m.LanguageCode != "" && m.LanguageCode != lang {
+			continue
+		}
+		if m.Text != nil {
+			t.messages = append(t.messages, m.Text.Text...)
+		}
+		if m.Payload != nil {
+			t.payloads = append(t.payloads, m.Payload)
+		}
+	}
+	for _, a := range f.SetParameterActions {
+		if a.Value == nil {
+			delete(t.s.params, a.Parameter)
+			continue
+		}
+		t.s.params[a.Parameter] = a.Value
+	}
+	if f.Webhook == "" {
+		return false, nil
+	}
+	return t.callWebhook(f)
+}
+
+func (t *turn) callWebhook(f *agent.Fulfillment) (bool, error) {
+	h, err := t.s.e.handler(f)
+	if err != nil {
+		return false, err
+	}
+	req, err := t.s.request(t, f.Tag)
+	if err != nil {
+		return false, err
+	}
+	res := req.InitializeResponse()
+	err = h(res, req)
+	t.webhooks = append(t.webhooks, &WebhookCall{Request: req, Response: res, Err: err})
+	if err != nil {
+		handled, herr := t.handleEvent("webhook.error")
+		if herr != nil || handled {
+			return true, herr
+		}
+		return false, fmt.Errorf("emulator: webhook %s tag %q: %w", f.Webhook, f.Tag, err)
+	}
+	return t.s.apply(t, res)
+}
+
+func (e *Emulator) handler(f *agent.Fulfillment) (ezcx.HandlerFunc, error) {
+	if w, ok := e.Agent.Webhooks[f.Webhook]; ok {
+		u, err := url.Parse(w.URI())
+		if err == nil {
+			if h, ok := e.Handlers[u.Path]; ok {
+				return h, nil
+			}
+		}
+	}
+	if h, ok := e.Handlers[f.Tag]; ok {
+		return h, nil
+	}
+	return nil, fmt.Errorf("%w for webhook %s tag %q", ErrNoHandler, f.Webhook, f.Tag)
+}
+
+// apply merges a WebhookResponse back onto the session and reports whether
+// it transitioned the session.
+func (s *Session) apply(t *turn, res *ezcx.WebhookResponse) (bool, error) {
+	for _, msg := range res.GetFulfillmentResponse().GetMessages() {
+		t.messages = append(t.messages, msg.GetText().GetText()...)
+		if p := msg.GetPayload(); p != nil {
+			t.payloads = append(t.payloads, p.AsMap())
+		}
+	}
+	for k, v := range res.GetSessionInfo().GetParameters() {
+		if _, isNull := v.GetKind().(*structpb.Value_NullValue); isNull {
+			delete(s.params, k)
+			continue
+		}
+		s.params[k] = v.AsInterface()
+	}
+	for _, rp := range res.GetPageInfo().GetFormInfo().GetParameterInfo() {
+		for _, fs := range s.form {
+			if fs.param.DisplayName != rp.GetDisplayName() {
+				continue
+			}
+			fs.state = rp.GetState()
+			switch {
+			case fs.state == invalid || fs.state == empty:
+				fs.value = nil
+				delete(s.params, fs.param.DisplayName)
+			case rp.Value != nil:
+				fs.value = rp.Value.AsInterface()
+				s.params[fs.param.DisplayName] = fs.value
+			}
+		}
+	}
+	page, flow, err := s.resolveTarget(res.GetTargetPage(), res.GetTargetFlow())
+	if err != nil || (page == "" && flow == "") {
+		return false, err
+	}
+	return true, s.transition(t, page, flow)
+}
+
+// resolveTarget maps the resource names in a WebhookResponse to the
+// display names used in the export.
+func (s *Session) resolveTarget(page, flow string) (string, string, error) {
+	if flow != "" {
+		id := lastSegment(flow)
+		for _, f := range s.e.Agent.Flows {
+			if f.Name == id || f.DisplayName == id {
+				return "", f.DisplayName, nil
+			}
+		}
+		return "", "", fmt.Errorf("emulator: unknown target flow %s", flow)
+	}
+	if page == "" {
+		return "", "", nil
+	}
+	id := lastSegment(page)
+	switch id {
+	case StartPage, EndSession, EndFlow, CurrentPage, PreviousPage:
+		return id, "", nil
+	}
+	for _, p := range s.flow.Pages {
+		if p.Name == id || p.DisplayName == id {
+			return p.DisplayName, "", nil
+		}
+	}
+	return "", "", fmt.Errorf("emulator: unknown target page %s", page)
+}
+
+// transition moves the session to targetPage in the current flow, or to
+// the start page of targetFlow.  Targets are display names.
+func (s *Session) transition(t *turn, targetPage, targetFlow string) error {
+	if targetFlow != "" {
+		f, ok := s.e.Agent.Flows[targetFlow]
+		if !ok {
+			return fmt.Errorf("emulator: unknown flow %q", targetFlow)
+		}
+		s.history = append(s.history, location{s.flow, s.page})
+		s.calls = append(s.calls, location{s.flow, s.page})
+		s.flow, s.page = f, nil
+		t.entered = true
+		return nil
+	}
+	if targetPage == "" {
+		return nil
+	}
+	if id, ok := specialPages[targetPage]; ok {
+		targetPage = id
+	}
+	switch targetPage {
+	case CurrentPage:
+		return nil
+	case EndSession:
+		s.ended = true
+		return nil
+	case EndFlow:
+		if len(s.calls) == 0 {
+			s.ended = true
+			return nil
+		}
+		// Return to the calling page without rerunning its entry
+		// fulfillment; its routes are evaluated again.
+		caller := s.calls[len(s.calls)-1]
+		s.calls = s.calls[:len(s.calls)-1]
+		s.history = append(s.history, location{s.flow, s.page})
+		s.flow, s.page = caller.flow, caller.page
+		s.initForm()
+		t.returned = true
+		return nil
+	case PreviousPage:
+		if len(s.history) == 0 {
+			return nil
+		}
+		prev := s.history[len(s.history)-1]
+		s.history = s.history[:len(s.history)-1]
+		s.flow, s.page = prev.flow, prev.page
+		t.entered = true
+		return nil
+	case StartPage:
+		s.history = append(s.history, location{s.flow, s.page})
+		s.page = nil
+		t.entered = true
+		return nil
+	}
+	p, ok := s.flow.Pages[targetPage]
+	if !ok {
+		return fmt.Errorf("emulator: unknown page %q in flow %q", targetPage, s.flow.DisplayName)
+	}
+	s.history = append(s.history, location{s.flow, s.page})
+	s.page = p
+	t.entered = true
+	return nil
+}
+
+// initForm initializes the form of the page just entered; parameters
+// already set in the session are filled.
+func (s *Session) initForm() {
+	s.form = nil
+	if s.page == nil {
+		return
+	}
+	for _, p := range s.page.Parameters() {
+		fs := &formState{param: p, state: empty}
+		v, ok := s.params[p.DisplayName]
+		if !ok && p.DefaultValue != nil {
+			v, ok = p.DefaultValue, true
+			s.params[p.DisplayName] = v
+		}
+		if ok {
+			fs.value = v
+			fs.state = filled
+		}
+		s.form = append(s.form, fs)
+	}
+}
+
+// collecting returns the first required form parameter that isn't filled.
+func (s *Session) collecting() *formState {
+	for _, fs := range s.form {
+		if fs.param.Required && fs.state != filled {
+			return fs
+		}
+	}
+	return nil
+}
+
+func pageName(p *agent.Page) string {
+	if p == nil {
+		return "Start Page"
+	}
+	return p.DisplayName
+}
+
+func intentName(i *agent.Intent) string {
+	if i == nil {
+		return ""
+	}
+	return i.DisplayName
+}
+
+func lastSegment(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
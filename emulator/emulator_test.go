@@ -0,0 +1,231 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/agent"
+)
+
+// cxOrder serves every tag of the coffee agent's webhook.
+func cxOrder(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+	params := req.GetSessionParameters()
+	switch req.GetFulfillmentInfo().GetTag() {
+	case "start-order":
+		res.AddTextResponse("Great choice.")
+		return res.AddSessionParameters(map[string]any{"order_id": "A1"})
+	case "confirm-order":
+		if params["quantity"].(float64) > 10 {
+			res.AddTextResponse("That's too many.")
+			res.PageInfo = &cx.PageInfo{FormInfo: &cx.PageInfo_FormInfo{ParameterInfo: []*cx.PageInfo_FormInfo_ParameterInfo{
+				{DisplayName: "quantity", State: cx.PageInfo_FormInfo_ParameterInfo_INVALID},
+			}}}
+			page := req.GetPageInfo().GetCurrentPage()
+			res.Transition = &cx.WebhookResponse_TargetPage{TargetPage: page[:strings.LastIndex(page, "/")+1] + CurrentPage}
+			return nil
+		}
+		res.AddTextResponse(fmt.Sprintf("%v %s coffees coming up.", params["quantity"], params["size"]))
+		return nil
+	case "goodbye":
+		res.AddTextResponse("Bye!")
+		return res.AddSessionParameters(map[string]any{"order_id": nil})
+	}
+	return fmt.Errorf("unexpected tag %q", req.GetFulfillmentInfo().GetTag())
+}
+
+func newEmulator(t *testing.T) *Emulator {
+	a, err := agent.Load("../agent/testdata/coffee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(a, map[string]ezcx.HandlerFunc{"/order": cxOrder})
+}
+
+func say(t *testing.T, s *Session, text string, want ...string) *Response {
+	t.Helper()
+	res, err := s.Text(context.Background(), text)
+	if err != nil {
+		t.Fatalf("%q: %v", text, err)
+	}
+	if !reflect.DeepEqual(res.Messages, want) {
+		t.Errorf("%q: messages = %q, want %q", text, res.Messages, want)
+	}
+	return res
+}
+
+func TestConversation(t *testing.T) {
+	s, err := newEmulator(t).NewSession(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := say(t, s, "Hi!", "Welcome to Coffee! What can I get you?")
+	if res.Intent != "Default Welcome Intent" || res.Page != "Start Page" {
+		t.Errorf("intent = %q, page = %q", res.Intent, res.Page)
+	}
+
+	res = say(t, s, "I'd like a BIG coffee", "Great choice.", "Let's get your order started.", "How many?")
+	if res.Page != "Order" || res.Params["size"] != "large" || res.Params["order_id"] != "A1" {
+		t.Errorf("page = %q, params = %v", res.Page, res.Params)
+	}
+	req := res.Webhooks[0].Request
+	if req.GetIntentInfo().GetDisplayName() != "order.coffee" || req.GetIntentInfo().GetParameters()["size"].GetOriginalValue() != "big" {
+		t.Errorf("start-order intent info = %v", req.GetIntentInfo())
+	}
+
+	say(t, s, "banana", "Sorry, what was that?")
+
+	res = say(t, s, "two", "2 large coffees coming up.", "Anything else?")
+	req = res.Webhooks[0].Request
+	if req.GetPageInfo().GetDisplayName() != "Order" || len(req.GetPageInfo().GetFormInfo().GetParameterInfo()) != 2 {
+		t.Errorf("confirm-order page info = %v", req.GetPageInfo())
+	}
+	if q := req.GetPageInfo().GetFormInfo().GetParameterInfo()[1]; q.State != cx.PageInfo_FormInfo_ParameterInfo_FILLED || !q.JustCollected {
+		t.Errorf("quantity = %v", q)
+	}
+
+	res = say(t, s, "that's all", "Bye!")
+	if !res.Ended || !s.Ended() {
+		t.Error("session didn't end")
+	}
+	if _, ok := res.Params["order_id"]; ok {
+		t.Error("order_id wasn't deleted")
+	}
+	_, err = s.Text(context.Background(), "hello?")
+	if !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("err = %v, want ErrSessionEnded", err)
+	}
+}
+
+func TestSubFlow(t *testing.T) {
+	e := newEmulator(t)
+	e.Agent.Flows["Loyalty"] = &agent.Flow{Name: "loyalty", DisplayName: "Loyalty", TransitionRoutes: []*agent.Route{{
+		Condition: "true",
+		TriggerFulfillment: &agent.Fulfillment{Messages: []*agent.Message{
+			{Text: &agent.Text{Text: []string{"You have 3 points."}}},
+		}},
+		TargetPage: "END_FLOW",
+	}}}
+	confirm := e.Agent.Flows["Default Start Flow"].Pages["Confirm"]
+	confirm.TransitionRoutes = append(confirm.TransitionRoutes, &agent.Route{Intent: "Default Welcome Intent", TargetFlow: "Loyalty"})
+	s, err := e.NewSession(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	say(t, s, "I'd like a large coffee", "Great choice.", "Let's get your order started.", "How many?")
+	say(t, s, "two", "2 large coffees coming up.", "Anything else?")
+
+	// END_FLOW in the sub-flow returns to the calling page.
+	res := say(t, s, "Hi!", "You have 3 points.")
+	if res.Ended || res.Flow != "Default Start Flow" || res.Page != "Confirm" {
+		t.Errorf("ended = %v, flow = %q, page = %q; want the Confirm page", res.Ended, res.Flow, res.Page)
+	}
+	res = say(t, s, "that's all", "Bye!")
+	if !res.Ended {
+		t.Error("session didn't end")
+	}
+}
+
+func TestFormReprompt(t *testing.T) {
+	s, err := newEmulator(t).NewSession(map[string]any{"size": "small"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	say(t, s, "i want a coffee", "Great choice.", "Let's get your order started.", "How many?")
+	say(t, s, "12", "That's too many.", "How many?")
+	if _, ok := s.Params()["quantity"]; ok {
+		t.Error("invalidated quantity is still set")
+	}
+	say(t, s, "one", "1 small coffees coming up.", "Anything else?")
+	if _, page := s.Page(); page != "Confirm" {
+		t.Errorf("page = %q, want Confirm", page)
+	}
+}
+
+func TestParamReprompt(t *testing.T) {
+	s, err := newEmulator(t).NewSession(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	say(t, s, "I want a coffee", "Great choice.", "Let's get your order started.", "What size?")
+	say(t, s, "venti", "We have small, medium and large.")
+	say(t, s, "regular", "How many?")
+}
+
+func TestEvent(t *testing.T) {
+	s, err := newEmulator(t).NewSession(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.Event(context.Background(), "sys.no-input-default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Messages, []string{"Are you still there?"}) {
+		t.Errorf("messages = %q", res.Messages)
+	}
+	_, err = s.Event(context.Background(), "custom.unhandled")
+	if err == nil {
+		t.Error("unhandled event succeeded")
+	}
+}
+
+func TestNoHandler(t *testing.T) {
+	e := newEmulator(t)
+	e.Handlers = nil
+	s, err := e.NewSession(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Text(context.Background(), "I want a coffee")
+	if !errors.Is(err, ErrNoHandler) {
+		t.Errorf("err = %v, want ErrNoHandler", err)
+	}
+}
+
+func TestEval(t *testing.T) {
+	s := &Session{params: map[string]any{"size": "large", "quantity": 2.0}}
+	tests := []struct {
+		cond string
+		want bool
+	}{
+		{"true", true},
+		{`$session.params.size = "large"`, true},
+		{"$session.params.size = large", true},
+		{"$session.params.size != null", true},
+		{"$session.params.missing = null", true},
+		{"$session.params.quantity >= 2 AND $session.params.quantity < 3", true},
+		{`$session.params.size = "small" OR $session.params.quantity > 1`, true},
+		{`$session.params.size = "small" AND true`, false},
+		{"$page.params.status = \"FINAL\"", false},
+	}
+	for _, tc := range tests {
+		got, err := s.eval(tc.cond)
+		if err != nil || got != tc.want {
+			t.Errorf("eval(%s) = %v, %v; want %v", tc.cond, got, err, tc.want)
+		}
+	}
+	_, err := s.eval("$sys.func.IF(...)")
+	if err == nil {
+		t.Error("eval of an unsupported condition succeeded")
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emulator
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/googlecloudplatform/ezcx/agent"
+)
+
+type matchedParam struct {
+	original string
+	resolved any
+}
+
+// routes returns the routes in scope: the page's routes and route groups,
+// then the flow's.
+func (s *Session) routes() []*agent.Route {
+	var routes []*agent.Route
+	if s.page != nil {
+		routes = append(routes, s.page.TransitionRoutes...)
+		routes = append(routes, s.groupRoutes(s.page.TransitionRouteGroups)...)
+	}
+	routes = append(routes, s.flow.TransitionRoutes...)
+	routes = append(routes, s.groupRoutes(s.flow.TransitionRouteGroups)...)
+	return routes
+}
+
+func (s *Session) groupRoutes(names []string) []*agent.Route {
+	var routes []*agent.Route
+	for _, name := range names {
+		g, ok := s.flow.RouteGroups[name]
+		if !ok {
+			g, ok = s.e.Agent.RouteGroups[name]
+		}
+		if ok {
+			routes = append(routes, g.TransitionRoutes...)
+		}
+	}
+	return routes
+}
+
+// matchIntent returns the first route in scope whose intent has a training
+// phrase matching text.
+func (s *Session) matchIntent(text string) (*agent.Route, *agent.Intent, map[string]matchedParam) {
+	for _, r := range s.routes() {
+		if r.Intent == "" {
+			continue
+		}
+		intent, ok := s.e.Agent.Intents[r.Intent]
+		if !ok {
+			continue
+		}
+		params, ok := s.matchPhrases(intent, text)
+		if ok {
+			return r, intent, params
+		}
+	}
+	return nil, nil, nil
+}
+
+func (s *Session) matchPhrases(intent *agent.Intent, text string) (map[string]matchedParam, bool) {
+	input := normalize(text)
+	for _, tp := range intent.TrainingPhrases[s.e.language()] {
+		var pattern strings.Builder
+		var ids []string
+		pattern.WriteString("^")
+		for _, part := range tp.Parts {
+			if part.ParameterID != "" {
+				pattern.WriteString("(.+?)")
+				ids = append(ids, part.ParameterID)
+				continue
+			}
+			pattern.WriteString(regexp.QuoteMeta(normalizeSpace(part.Text)))
+		}
+		pattern.WriteString("$")
+		re, err := regexp.Compile(strings.Join(strings.Fields(pattern.String()), " "))
+		if err != nil {
+			continue
+		}
+		m := re.FindStringSubmatch(input)
+		if m == nil {
+			continue
+		}
+		params := make(map[string]matchedParam)
+		ok := true
+		for i, id := range ids {
+			entityType := "@sys.any"
+			if p := intent.Parameter(id); p != nil {
+				entityType = p.EntityType
+			}
+			v, resolved := s.resolve(entityType, m[i+1])
+			if !resolved {
+				ok = false
+				break
+			}
+			params[id] = matchedParam{original: m[i+1], resolved: v}
+		}
+		if ok {
+			return params, true
+		}
+	}
+	return nil, false
+}
+
+// normalize lowercases text, drops punctuation other than apostrophes and
+// collapses whitespace.
+func normalize(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) && r != '\'' {
+			return ' '
+		}
+		return unicode.ToLower(r)
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// normalizeSpace is normalize for a fragment of a training phrase; leading
+// and trailing spaces are kept as single spaces.
+func normalizeSpace(text string) string {
+	n := normalize(text)
+	if strings.HasPrefix(text, " ") && n != "" {
+		n = " " + n
+	}
+	if strings.HasSuffix(text, " ") {
+		n += " "
+	}
+	return n
+}
+
+var (
+	numberRe = regexp.MustCompile(`-?\d+(\.\d+)?`)
+	emailRe  = regexp.MustCompile(`[^@\s]+@[^@\s]+\.[^@\s]+`)
+	phoneRe  = regexp.MustCompile(`\+?[\d\s().-]{7,}\d`)
+)
+
+var numberWords = map[string]float64{
+	"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+	"a": 1, "an": 1, "a couple": 2, "a dozen": 12,
+}
+
+// resolve resolves text to a value of the entity type.  Custom entity types
+// are matched against their entities' synonyms; common system entities are
+// parsed and any other system entity resolves to the text itself.
+func (s *Session) resolve(entityType, text string) (any, bool) {
+	text = strings.TrimSpace(text)
+	switch entityType {
+	case "", "@sys.any":
+		return text, text != ""
+	case "@sys.number", "@sys.number-integer", "@sys.cardinal", "@sys.number-sequence":
+		if m := numberRe.FindString(text); m != "" {
+			f, err := strconv.ParseFloat(m, 64)
+			return f, err == nil
+		}
+		f, ok := numberWords[normalize(text)]
+		return f, ok
+	case "@sys.email":
+		m := emailRe.FindString(text)
+		return m, m != ""
+	case "@sys.phone-number":
+		m := phoneRe.FindString(text)
+		return strings.TrimSpace(m), m != ""
+	}
+	if strings.HasPrefix(entityType, "@sys.") {
+		return text, text != ""
+	}
+	et, ok := s.e.Agent.EntityTypes[entityType]
+	if !ok {
+		return text, text != ""
+	}
+	input := normalize(text)
+	for _, e := range et.Entities[s.e.language()] {
+		for _, syn := range append([]string{e.Value}, e.Synonyms...) {
+			syn = normalize(syn)
+			if input == syn || containsWords(input, syn) {
+				return e.Value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func containsWords(text, words string) bool {
+	return words != "" && strings.Contains(" "+text+" ", " "+words+" ")
+}
+
+// matchCondition returns the first condition-only route in scope whose
+// condition is true.  On a page, only the page's condition routes are
+// considered; on the start page, the flow's.
+func (s *Session) matchCondition() (*agent.Route, error) {
+	var routes []*agent.Route
+	if s.page != nil {
+		routes = append(routes, s.page.TransitionRoutes...)
+		routes = append(routes, s.groupRoutes(s.page.TransitionRouteGroups)...)
+	} else {
+		routes = append(routes, s.flow.TransitionRoutes...)
+		routes = append(routes, s.groupRoutes(s.flow.TransitionRouteGroups)...)
+	}
+	for _, r := range routes {
+		if r.Intent != "" || r.Condition == "" {
+			continue
+		}
+		ok, err := s.eval(r.Condition)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emulator
+
+import (
+	"context"
+	"fmt"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/google/uuid"
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/internal/structpbutil"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// agentName is the agent that resource names in emulated requests refer
+// to.
+var agentName = ezcx.AgentName{Project: "ezcx-emulator", Location: "global", Agent: "ezcx-emulator"}
+
+// request builds the WebhookRequest CX would send from the current state.
+func (s *Session) request(t *turn, tag string) (*ezcx.WebhookRequest, error) {
+	req := ezcx.NewWebhookRequest()
+	req.DetectIntentResponseId = uuid.New().String()
+	req.LanguageCode = s.e.language()
+	req.FulfillmentInfo = &cx.WebhookRequest_FulfillmentInfo{Tag: tag}
+	switch {
+	case t.text != "":
+		req.Query = &cx.WebhookRequest_Text{Text: t.text}
+	case t.event != "":
+		req.Query = &cx.WebhookRequest_TriggerEvent{TriggerEvent: t.event}
+	}
+
+	params, err := structpbutil.ToProtoMap(s.params)
+	if err != nil {
+		return nil, fmt.Errorf("emulator: session parameters: %w", err)
+	}
+	req.SessionInfo = &cx.SessionInfo{
+		Session:    ezcx.SessionName{AgentName: agentName, Session: s.ID}.String(),
+		Parameters: params,
+	}
+
+	pageID := StartPage
+	if s.page != nil {
+		pageID = s.page.Name
+	}
+	req.PageInfo = &cx.PageInfo{
+		CurrentPage: ezcx.PageName{FlowName: ezcx.FlowName{AgentName: agentName, Flow: s.flow.Name}, Page: pageID}.String(),
+		DisplayName: pageName(s.page),
+	}
+	if len(s.form) > 0 {
+		infos := make([]*cx.PageInfo_FormInfo_ParameterInfo, 0, len(s.form))
+		for _, fs := range s.form {
+			info := &cx.PageInfo_FormInfo_ParameterInfo{
+				DisplayName:   fs.param.DisplayName,
+				Required:      fs.param.Required,
+				State:         fs.state,
+				JustCollected: fs.justCollected,
+			}
+			if fs.value != nil {
+				info.Value, err = structpb.NewValue(fs.value)
+				if err != nil {
+					return nil, fmt.Errorf("emulator: form parameter %s: %w", fs.param.DisplayName, err)
+				}
+			}
+			infos = append(infos, info)
+		}
+		req.PageInfo.FormInfo = &cx.PageInfo_FormInfo{ParameterInfo: infos}
+	}
+
+	if t.intent != nil {
+		info := &cx.WebhookRequest_IntentInfo{
+			LastMatchedIntent: ezcx.IntentName{AgentName: agentName, Intent: t.intent.Name}.String(),
+			DisplayName:       t.intent.DisplayName,
+			Confidence:        1,
+			Parameters:        make(map[string]*cx.WebhookRequest_IntentInfo_IntentParameterValue),
+		}
+		for name, p := range t.intentParams {
+			v, err := structpb.NewValue(p.resolved)
+			if err != nil {
+				return nil, fmt.Errorf("emulator: intent parameter %s: %w", name, err)
+			}
+			info.Parameters[name] = &cx.WebhookRequest_IntentInfo_IntentParameterValue{
+				OriginalValue: p.original,
+				ResolvedValue: v,
+			}
+		}
+		req.IntentInfo = info
+	}
+
+	if len(t.messages) > 0 {
+		req.Messages = []*cx.ResponseMessage{{
+			Message: &cx.ResponseMessage_Text_{Text: &cx.ResponseMessage_Text{Text: t.messages}},
+		}}
+	}
+	ctx := t.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req.SetContext(ctx)
+	return req, nil
+}
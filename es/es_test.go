@@ -0,0 +1,173 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/ezcxtest"
+)
+
+const session = "projects/p/agent/sessions/s1"
+
+var sample = `{
+	"responseId": "r1",
+	"session": "` + session + `",
+	"queryResult": {
+		"queryText": "a large latte",
+		"action": "order.coffee",
+		"parameters": {"size": "large"},
+		"languageCode": "en",
+		"intent": {"displayName": "order.coffee"},
+		"outputContexts": [
+			{"name": "` + session + `/contexts/ezcx-params", "lifespanCount": 49, "parameters": {"count": 1, "size": "small"}},
+			{"name": "` + session + `/contexts/order", "lifespanCount": 2, "parameters": {"drink": "latte"}}
+		],
+		"someNewField": true
+	},
+	"originalDetectIntentRequest": {"source": "web", "payload": {"user": "ada"}}
+}`
+
+func TestWebhookRequest(t *testing.T) {
+	req, err := WebhookRequestFromReader(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.SessionID() != "s1" {
+		t.Errorf("SessionID() = %q", req.SessionID())
+	}
+	if v, ok := req.GetParameter("size"); !ok || v != "large" {
+		t.Errorf("GetParameter(size) = %v, %v", v, ok)
+	}
+	if got := req.GetContextParameters("order")["drink"]; got != "latte" {
+		t.Errorf("order context drink = %v", got)
+	}
+	if req.GetOutputContext("missing") != nil {
+		t.Error("found a missing context")
+	}
+	if got := req.GetPayload()["user"]; got != "ada" {
+		t.Errorf("payload user = %v", got)
+	}
+
+	res := req.InitializeResponse()
+	res.AddTextResponse("one", "two")
+	err = res.SetOutputContext("order", 5, map[string]any{"drink": "mocha"})
+	if err == nil {
+		err = res.SetOutputContext("order", 0, nil)
+	}
+	if err == nil {
+		err = res.SetFollowupEvent("CONFIRM", "en", map[string]any{"n": 1})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.OutputContexts) != 1 || res.OutputContexts[0].Name != session+"/contexts/order" || res.OutputContexts[0].LifespanCount != 0 {
+		t.Errorf("output contexts = %v", res.OutputContexts)
+	}
+	if got := res.FulfillmentMessages[0].GetText().GetText(); len(got) != 2 {
+		t.Errorf("messages = %v", got)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	h := HandlerFunc(func(res *WebhookResponse, req *WebhookRequest) error {
+		res.AddTextResponse("hi " + req.GetQueryResult().GetQueryText())
+		return nil
+	})
+	r := httptest.NewRequest(http.MethodPost, "/es", strings.NewReader(sample))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var body struct {
+		FulfillmentMessages []struct {
+			Text struct{ Text []string }
+		}
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	if len(body.FulfillmentMessages) != 1 || body.FulfillmentMessages[0].Text.Text[0] != "hi a large latte" {
+		t.Errorf("response = %s", w.Body)
+	}
+}
+
+var counter = Shared(func(t Turn) error {
+	n, _ := t.Params()["count"].(float64)
+	err := t.SetParams(map[string]any{"count": n + 1, "size": nil})
+	if err != nil {
+		return err
+	}
+	t.AddTextResponse(fmt.Sprintf("%s: %s #%v", t.Tag(), t.Text(), t.Params()["count"]))
+	return nil
+})
+
+func TestShared(t *testing.T) {
+	req, err := WebhookRequestFromReader(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := req.InitializeResponse()
+	err = counter.ES()(res, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.FulfillmentMessages[0].GetText().GetText()[0]; got != "order.coffee: a large latte #2" {
+		t.Errorf("ES response = %q", got)
+	}
+	c := res.OutputContexts[0]
+	if contextID(c.Name) != ParamsContext || c.LifespanCount != ParamsLifespan || fmt.Sprint(c.Parameters.AsMap()) != "map[count:2]" {
+		t.Errorf("ES params context = %v", c)
+	}
+
+	cxReq := ezcxtest.NewRequest().Tag("order.coffee").Text("a latte").SessionParam("count", 4).MustBuild()
+	cxRes := cxReq.InitializeResponse()
+	err = counter.CX()(cxRes, cxReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ezcxtest.AssertText(t, cxRes, "order.coffee: a latte #5")
+	ezcxtest.AssertSessionParam(t, cxRes, "count", 5)
+}
+
+func TestSharedDeletedParams(t *testing.T) {
+	req := ezcxtest.NewRequest().SessionParam("size", "small").MustBuild()
+	res := req.InitializeResponse()
+	err := counter.CX()(res, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := (&cxTurn{req, res}).Params()["size"]; ok {
+		t.Errorf("size = %v, want it deleted", v)
+	}
+}
+
+func TestSharedFirstTurn(t *testing.T) {
+	req := ezcx.NewWebhookRequest()
+	req.SessionInfo = &cx.SessionInfo{Session: "projects/p/locations/global/agents/a/sessions/s"}
+	res := req.InitializeResponse()
+	err := counter.CX()(res, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ezcxtest.AssertText(t, res, ":  #1")
+	ezcxtest.AssertSessionParam(t, res, "count", 1)
+}
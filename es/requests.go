@@ -0,0 +1,164 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package es provides Dialogflow ES (v2) webhook support with the same
+// ergonomics as ezcx: WebhookRequest and WebhookResponse wrap the v2
+// protos, and HandlerFunc serves them over HTTP.  Shared handlers written
+// against Turn serve both ES and CX.
+package es
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	df "cloud.google.com/go/dialogflow/apiv2/dialogflowpb"
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/gcp/logger"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type WebhookRequest struct {
+	df.WebhookRequest
+	ctx func() context.Context
+	req *http.Request
+}
+
+func NewWebhookRequest() *WebhookRequest {
+	return new(WebhookRequest)
+}
+
+// WebhookRequestFromReader decodes a WebhookRequest.  As with ezcx, fields
+// unknown to the protos are ignored.
+func WebhookRequestFromReader(rd io.Reader) (*WebhookRequest, error) {
+	b, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	req := NewWebhookRequest()
+	unmarshaler := protojson.UnmarshalOptions{AllowPartial: true, DiscardUnknown: true}
+	err = unmarshaler.Unmarshal(b, &req.WebhookRequest)
+	if err != nil {
+		return nil, ezcx.ErrUnmarshalWrapper("es.WebhookRequestFromReader", err)
+	}
+	return req, nil
+}
+
+func WebhookRequestFromRequest(r *http.Request) (*WebhookRequest, error) {
+	req, err := WebhookRequestFromReader(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.req = r
+	return req, nil
+}
+
+// Request returns the underlying http.Request, if any.
+func (req *WebhookRequest) Request() *http.Request {
+	return req.req
+}
+
+func (req *WebhookRequest) Context() context.Context {
+	if req.ctx == nil {
+		return context.Background()
+	}
+	return req.ctx()
+}
+
+// SetContext replaces the WebhookRequest's context in place.
+func (req *WebhookRequest) SetContext(ctx context.Context) {
+	req.ctx = func() context.Context { return ctx }
+}
+
+// Logger returns the request scoped *slog.Logger, pre-populated with the
+// session ID, action and intent.
+func (req *WebhookRequest) Logger() *slog.Logger {
+	if lg, ok := req.Context().Value(ezcx.Logger).(*slog.Logger); ok {
+		return lg
+	}
+	return req.childLogger(logger.NewSlog())
+}
+
+func (req *WebhookRequest) childLogger(lg *slog.Logger) *slog.Logger {
+	attrs := make([]any, 0, 3)
+	if id := req.SessionID(); id != "" {
+		attrs = append(attrs, slog.String("session_id", id))
+	}
+	if action := req.GetQueryResult().GetAction(); action != "" {
+		attrs = append(attrs, slog.String("action", action))
+	}
+	if intent := req.GetQueryResult().GetIntent().GetDisplayName(); intent != "" {
+		attrs = append(attrs, slog.String("intent", intent))
+	}
+	return lg.With(attrs...)
+}
+
+// SessionID returns the last segment of the session's resource name.
+func (req *WebhookRequest) SessionID() string {
+	s := req.GetSession()
+	return s[strings.LastIndex(s, "/")+1:]
+}
+
+// GetParameters returns the query result's parameters.
+func (req *WebhookRequest) GetParameters() map[string]any {
+	return structMap(req.GetQueryResult().GetParameters())
+}
+
+func (req *WebhookRequest) GetParameter(key string) (any, bool) {
+	v, ok := req.GetQueryResult().GetParameters().GetFields()[key]
+	if !ok {
+		return nil, false
+	}
+	return v.AsInterface(), true
+}
+
+// GetOutputContext returns the active context with the given short name
+// e.g. "order", or nil.
+func (req *WebhookRequest) GetOutputContext(name string) *df.Context {
+	for _, c := range req.GetQueryResult().GetOutputContexts() {
+		if contextID(c.GetName()) == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// GetContextParameters returns the parameters of the active context name.
+func (req *WebhookRequest) GetContextParameters(name string) map[string]any {
+	return structMap(req.GetOutputContext(name).GetParameters())
+}
+
+// GetPayload returns the payload of the original detect intent request,
+// e.g. the integration's platform data.
+func (req *WebhookRequest) GetPayload() map[string]any {
+	return structMap(req.GetOriginalDetectIntentRequest().GetPayload())
+}
+
+func (req *WebhookRequest) InitializeResponse() *WebhookResponse {
+	return &WebhookResponse{session: req.GetSession()}
+}
+
+func contextID(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+func structMap(s *structpb.Struct) map[string]any {
+	if s == nil {
+		return nil
+	}
+	return s.AsMap()
+}
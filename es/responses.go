@@ -0,0 +1,102 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package es
+
+import (
+	"bytes"
+	"io"
+
+	df "cloud.google.com/go/dialogflow/apiv2/dialogflowpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type WebhookResponse struct {
+	df.WebhookResponse
+	session string // for output context names
+}
+
+func NewWebhookResponse() *WebhookResponse {
+	return new(WebhookResponse)
+}
+
+func (res *WebhookResponse) AddTextResponse(txts ...string) {
+	res.FulfillmentMessages = append(res.FulfillmentMessages, &df.Intent_Message{
+		Message: &df.Intent_Message_Text_{Text: &df.Intent_Message_Text{Text: txts}},
+	})
+}
+
+// SetOutputContext sets the context name, a short name e.g. "order", for
+// lifespan turns with the given parameters.  A lifespan of 0 clears the
+// context.
+func (res *WebhookResponse) SetOutputContext(name string, lifespan int32, params map[string]any) error {
+	s, err := structpb.NewStruct(params)
+	if err != nil {
+		return err
+	}
+	full := res.session + "/contexts/" + name
+	for _, c := range res.OutputContexts {
+		if c.Name == full {
+			c.LifespanCount, c.Parameters = lifespan, s
+			return nil
+		}
+	}
+	res.OutputContexts = append(res.OutputContexts, &df.Context{Name: full, LifespanCount: lifespan, Parameters: s})
+	return nil
+}
+
+// SetFollowupEvent triggers the event name, with parameters, after the
+// response.
+func (res *WebhookResponse) SetFollowupEvent(name, languageCode string, params map[string]any) error {
+	s, err := structpb.NewStruct(params)
+	if err != nil {
+		return err
+	}
+	res.FollowupEventInput = &df.EventInput{Name: name, LanguageCode: languageCode, Parameters: s}
+	return nil
+}
+
+func (res *WebhookResponse) SetPayload(m map[string]any) error {
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		return err
+	}
+	res.Payload = s
+	return nil
+}
+
+func (res *WebhookResponse) AddPayload(m map[string]any) error {
+	if res.Payload == nil {
+		res.Payload = &structpb.Struct{Fields: make(map[string]*structpb.Value)}
+	}
+	for k, v := range m {
+		pv, err := structpb.NewValue(v)
+		if err != nil {
+			return err
+		}
+		res.Payload.Fields[k] = pv
+	}
+	return nil
+}
+
+func (res *WebhookResponse) WriteResponse(w io.Writer) error {
+	m := protojson.MarshalOptions{Indent: "\t"}
+	b, err := m.Marshal(&res.WebhookResponse)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, bytes.NewReader(b))
+	return err
+}
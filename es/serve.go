@@ -0,0 +1,30 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package es
+
+import (
+	"net/http"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/internal/webhook"
+)
+
+// HandlerFunc is the ES analog of ezcx.HandlerFunc.  It implements
+// http.Handler, so it can be registered with an ezcx.Server's ServeMux.
+type HandlerFunc func(*WebhookResponse, *WebhookRequest) error
+
+func (h HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	webhook.ServeHTTP(w, r, ezcx.Logger, WebhookRequestFromRequest, (*WebhookRequest).childLogger, h)
+}
@@ -0,0 +1,153 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package es
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/googlecloudplatform/ezcx"
+)
+
+// ParamsContext is the output context ES turns keep parameters set with
+// Turn.SetParams in, for ParamsLifespan turns.
+const (
+	ParamsContext  = "ezcx-params"
+	ParamsLifespan = 50
+)
+
+// Turn is what ES and CX webhook calls have in common.
+type Turn interface {
+	Context() context.Context
+	Logger() *slog.Logger
+	SessionID() string
+	LanguageCode() string
+	// Tag is the CX fulfillment tag or the ES action.
+	Tag() string
+	// Intent is the matched intent's display name.
+	Intent() string
+	// Text is the end-user's input.
+	Text() string
+	// Params are the CX session parameters, or the ES query result's
+	// parameters over those in ParamsContext.
+	Params() map[string]any
+	// SetParams sets CX session parameters, or the ES parameters in
+	// ParamsContext.
+	SetParams(m map[string]any) error
+	AddTextResponse(txts ...string)
+}
+
+// Shared is a handler for both ES and CX.
+type Shared func(Turn) error
+
+// CX returns the ezcx.HandlerFunc calling s.
+func (s Shared) CX() ezcx.HandlerFunc {
+	return func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		return s(&cxTurn{req, res})
+	}
+}
+
+// ES returns the HandlerFunc calling s.
+func (s Shared) ES() HandlerFunc {
+	return func(res *WebhookResponse, req *WebhookRequest) error {
+		return s(&esTurn{req: req, res: res})
+	}
+}
+
+type cxTurn struct {
+	req *ezcx.WebhookRequest
+	res *ezcx.WebhookResponse
+}
+
+func (t *cxTurn) Context() context.Context { return t.req.Context() }
+func (t *cxTurn) Logger() *slog.Logger     { return t.req.Logger() }
+func (t *cxTurn) SessionID() string        { return t.req.SessionID() }
+func (t *cxTurn) LanguageCode() string     { return t.req.GetLanguageCode() }
+func (t *cxTurn) Tag() string              { return t.req.GetFulfillmentInfo().GetTag() }
+func (t *cxTurn) Intent() string           { return t.req.GetIntentInfo().GetDisplayName() }
+
+func (t *cxTurn) Text() string {
+	if s := t.req.GetText(); s != "" {
+		return s
+	}
+	return t.req.GetTranscript()
+}
+
+func (t *cxTurn) Params() map[string]any {
+	params := make(map[string]any)
+	for k, v := range t.req.GetSessionParameters() {
+		params[k] = v
+	}
+	// Parameters set earlier in this turn are visible; CX deletes those
+	// set to null.
+	for k, v := range t.res.GetSessionInfo().GetParameters() {
+		if x := v.AsInterface(); x != nil {
+			params[k] = x
+			continue
+		}
+		delete(params, k)
+	}
+	return params
+}
+
+func (t *cxTurn) SetParams(m map[string]any) error { return t.res.AddSessionParameters(m) }
+func (t *cxTurn) AddTextResponse(txts ...string)   { t.res.AddTextResponse(txts...) }
+
+type esTurn struct {
+	req *WebhookRequest
+	res *WebhookResponse
+	set map[string]any // parameters set this turn
+}
+
+func (t *esTurn) Context() context.Context { return t.req.Context() }
+func (t *esTurn) Logger() *slog.Logger     { return t.req.Logger() }
+func (t *esTurn) SessionID() string        { return t.req.SessionID() }
+func (t *esTurn) LanguageCode() string     { return t.req.GetQueryResult().GetLanguageCode() }
+func (t *esTurn) Tag() string              { return t.req.GetQueryResult().GetAction() }
+func (t *esTurn) Intent() string           { return t.req.GetQueryResult().GetIntent().GetDisplayName() }
+func (t *esTurn) Text() string             { return t.req.GetQueryResult().GetQueryText() }
+
+// merge sets the params in m, deleting those with nil values.
+func merge(dst, m map[string]any) map[string]any {
+	if dst == nil {
+		dst = make(map[string]any)
+	}
+	for k, v := range m {
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+func (t *esTurn) Params() map[string]any {
+	params := merge(t.req.GetContextParameters(ParamsContext), t.req.GetParameters())
+	return merge(params, t.set)
+}
+
+func (t *esTurn) SetParams(m map[string]any) error {
+	if t.set == nil {
+		t.set = make(map[string]any)
+	}
+	for k, v := range m {
+		t.set[k] = v
+	}
+	params := merge(t.req.GetContextParameters(ParamsContext), t.set)
+	return t.res.SetOutputContext(ParamsContext, ParamsLifespan, params)
+}
+
+func (t *esTurn) AddTextResponse(txts ...string) { t.res.AddTextResponse(txts...) }
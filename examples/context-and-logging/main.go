@@ -17,10 +17,10 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 
 	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/gcp/logger"
 )
 
 var (
@@ -29,23 +29,23 @@ var (
 
 func main() {
 	parent := context.Background()
-	lg := log.Default()
-	server := ezcx.NewServer(parent, ":"+PORT, lg)
+	h := logger.NewHandler(os.Stderr, nil)
+	server := ezcx.NewServer(parent, ":"+PORT, h)
 	server.HandleCx("/tell-a-joke", CxJokeHandler)
 	server.ListenAndServe(parent)
 }
 
 // Sends a joke upon invocation.. 
 func CxJokeHandler(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
-	lg := req.Logger()   // Access the logger via req.Logger (it's passed as a context value)
+	lg := req.Logger()   // Access the request scoped structured logger (it's passed as a context value)
 	ctx := req.Context() // Access the context, which is a proxy for (*http.Request).Context
 
 	joke, err := defaultJokesClient.get(ctx)
 	if err != nil {
-		lg.Println(err)
+		lg.Error("unable to get a joke", "error", err)
 		return err
 	}
-	lg.Println(joke.Joke) // added for testing purposes!
+	lg.Info("joke", "id", joke.Id, "joke", joke.Joke) // added for testing purposes!
 	res.AddTextResponse(joke.Joke)
 	return nil
 }
@@ -15,19 +15,19 @@
 package main
 
 import (
-	"bytes"
 	"io"
-	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
 	"testing"
 
 	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/ezcxtest"
 )
 
 // Unit (logical) testing for CxJokeHandler
 func TestCxJokeHandler(t *testing.T) {
-	req, err := ezcx.NewTestingWebhookRequest(nil, nil, nil)
+	req, err := ezcxtest.NewRequest().Tag("tell-a-joke").Build()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -36,21 +36,15 @@ func TestCxJokeHandler(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	res.WriteResponse(os.Stdout)
+	ezcxtest.AssertText(t, res, regexp.MustCompile(`.+`))
 }
 
 // Unit (HTTP) testing for CxJokeHandler
 func TestServeHTTPJokeHandler(t *testing.T) {
-	var buf bytes.Buffer
-	req, err := ezcx.NewTestingWebhookRequest(nil, nil, nil)
+	httpReq, err := ezcxtest.NewRequest().Tag("tell-a-joke").HTTPRequest("/tell-a-joke")
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = req.WriteRequest(&buf)
-	if err != nil {
-		t.Fatal(err)
-	}
-	httpReq := httptest.NewRequest(http.MethodPost, "/tell-a-joke", &buf)
 	w := httptest.NewRecorder()
 	hf := ezcx.HandlerFunc(CxJokeHandler)
 	hf.ServeHTTP(w, httpReq)
@@ -17,10 +17,10 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 
 	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/gcp/logger"
 )
 
 var (
@@ -29,9 +29,8 @@ var (
 
 func main() {
 	ctx := context.Background()
-	lg := log.Default()
-	lg.Println(PORT)
-	server := ezcx.NewServer(ctx, ":"+PORT, lg)
+	h := logger.NewHandler(os.Stderr, nil)
+	server := ezcx.NewServer(ctx, ":"+PORT, h)
 	deps := NewDependencies()
 	server.HandleCx("/confirm", deps.cxConfirm)
 	server.HandleCx("/hello", cxHello(deps))
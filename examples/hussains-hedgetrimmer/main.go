@@ -16,11 +16,11 @@ package main
 
 import (
 	"context"
-	"log"
 	"os"
 	"strings"
 
 	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/gcp/logger"
 )
 
 var (
@@ -29,8 +29,8 @@ var (
 
 func main() {
 	ctx := context.Background()
-	lg := log.Default()
-	server := ezcx.NewServer(ctx, ":"+PORT, lg)
+	h := logger.NewHandler(os.Stderr, nil)
+	server := ezcx.NewServer(ctx, ":"+PORT, h)
 	server.HandleCx("/trimmer", cxHedgeTrimmer)
 	server.ListenAndServe(ctx)
 }
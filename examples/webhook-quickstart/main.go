@@ -19,10 +19,10 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 
 	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/gcp/logger"
 )
 
 var (
@@ -31,8 +31,8 @@ var (
 
 func main() {
 	ctx := context.Background()
-	lg := log.Default()
-	server := ezcx.NewServer(ctx, ":"+PORT, lg)
+	h := logger.NewHandler(os.Stderr, nil)
+	server := ezcx.NewServer(ctx, ":"+PORT, h)
 	server.HandleCx("/confirm", cxConfirm)
 	server.ListenAndServe(ctx)
 }
@@ -0,0 +1,244 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcxtest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/googlecloudplatform/ezcx"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The Assert functions report failures with t.Errorf, followed by the whole
+// response, and return whether the assertion held.
+
+// AssertText asserts the response's text messages, flattened in order.
+// Each element of want is either a string, matched exactly, or a
+// *regexp.Regexp.
+func AssertText(t testing.TB, res *ezcx.WebhookResponse, want ...any) bool {
+	t.Helper()
+	got := Texts(res)
+	ok := len(got) == len(want)
+	for i := 0; ok && i < len(want); i++ {
+		switch w := want[i].(type) {
+		case string:
+			ok = got[i] == w
+		case *regexp.Regexp:
+			ok = w.MatchString(got[i])
+		default:
+			t.Fatalf("AssertText: want[%d] is a %T; want a string or *regexp.Regexp", i, w)
+		}
+	}
+	if !ok {
+		wl := make([]string, len(want))
+		for i, w := range want {
+			if re, isRe := w.(*regexp.Regexp); isRe {
+				// Show a matched line as common rather than as a change.
+				if i < len(got) && re.MatchString(got[i]) {
+					wl[i] = got[i]
+					continue
+				}
+				wl[i] = "/" + re.String() + "/"
+				continue
+			}
+			wl[i] = w.(string)
+		}
+		fail(t, res, "text messages differ (-want +got):\n%s", lineDiff(wl, got))
+	}
+	return ok
+}
+
+// AssertNoMessages asserts the response has no fulfillment messages.
+func AssertNoMessages(t testing.TB, res *ezcx.WebhookResponse) bool {
+	t.Helper()
+	n := len(res.GetFulfillmentResponse().GetMessages())
+	if n != 0 {
+		fail(t, res, "got %d messages, want none", n)
+	}
+	return n == 0
+}
+
+// AssertSessionParam asserts the session parameter name is set to want.
+// want is compared after conversion to a protobuf Value, so for example
+// the int 1 matches the number 1.
+func AssertSessionParam(t testing.TB, res *ezcx.WebhookResponse, name string, want any) bool {
+	t.Helper()
+	pv, ok := res.GetSessionInfo().GetParameters()[name]
+	if !ok {
+		fail(t, res, "session parameter %q is not set, want %s", name, jsonString(want))
+		return false
+	}
+	if _, isNull := pv.GetKind().(*structpb.Value_NullValue); isNull {
+		fail(t, res, "session parameter %q is deleted, want %s", name, jsonString(want))
+		return false
+	}
+	return assertValue(t, res, fmt.Sprintf("session parameter %q", name), pv.AsInterface(), want)
+}
+
+// AssertParamDeleted asserts the session parameter name is set to null,
+// which deletes it from the session.
+func AssertParamDeleted(t testing.TB, res *ezcx.WebhookResponse, name string) bool {
+	t.Helper()
+	pv, ok := res.GetSessionInfo().GetParameters()[name]
+	_, isNull := pv.GetKind().(*structpb.Value_NullValue)
+	if !ok || !isNull {
+		got := "not set"
+		if ok {
+			got = jsonString(pv.AsInterface())
+		}
+		fail(t, res, "session parameter %q is %s, want null", name, got)
+		return false
+	}
+	return true
+}
+
+// AssertTransition asserts the response transitions to target, which is
+// either a full page or flow resource name or its trailing ID.
+func AssertTransition(t testing.TB, res *ezcx.WebhookResponse, target string) bool {
+	t.Helper()
+	got := res.GetTargetPage()
+	if got == "" {
+		got = res.GetTargetFlow()
+	}
+	if got == "" || (got != target && !strings.HasSuffix(got, "/"+target)) {
+		if got == "" {
+			got = "no transition"
+		}
+		fail(t, res, "target is %s, want %s", got, target)
+		return false
+	}
+	return true
+}
+
+// AssertFormParamState asserts the state of the named form parameter in
+// the response's page info.
+func AssertFormParamState(t testing.TB, res *ezcx.WebhookResponse, name string, want cx.PageInfo_FormInfo_ParameterInfo_ParameterState) bool {
+	t.Helper()
+	for _, p := range res.GetPageInfo().GetFormInfo().GetParameterInfo() {
+		if p.GetDisplayName() == name {
+			if p.GetState() != want {
+				fail(t, res, "form parameter %q is %s, want %s", name, p.GetState(), want)
+				return false
+			}
+			return true
+		}
+	}
+	fail(t, res, "form parameter %q is not in the response, want %s", name, want)
+	return false
+}
+
+// AssertPayloadPath asserts the value at path in the response payload.
+// Paths are JSONPath-like: "$.order.items[0].sku"; the leading "$." is
+// optional.
+func AssertPayloadPath(t testing.TB, res *ezcx.WebhookResponse, path string, want any) bool {
+	t.Helper()
+	got, err := Lookup(res.GetPayload().AsMap(), path)
+	if err != nil {
+		fail(t, res, "payload %s: %v", path, err)
+		return false
+	}
+	return assertValue(t, res, "payload "+path, got, want)
+}
+
+// Texts returns the text of every text message in the response, in order.
+func Texts(res *ezcx.WebhookResponse) []string {
+	var txts []string
+	for _, msg := range res.GetFulfillmentResponse().GetMessages() {
+		txts = append(txts, msg.GetText().GetText()...)
+	}
+	return txts
+}
+
+// Lookup returns the value at the JSONPath-like path in v.  Supported
+// syntax is dotted keys, ["quoted keys"] and [n] list indices.
+func Lookup(v any, path string) (any, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	for path != "" {
+		var key string
+		var index = -1
+		switch {
+		case strings.HasPrefix(path, `["`):
+			end := strings.Index(path, `"]`)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated key in %q", path)
+			}
+			key, path = path[2:end], path[end+2:]
+		case strings.HasPrefix(path, "["):
+			end := strings.Index(path, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated index in %q", path)
+			}
+			n, err := strconv.Atoi(path[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("bad index in %q", path)
+			}
+			index, path = n, path[end+1:]
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			key, path = path[:end], path[end:]
+		}
+		path = strings.TrimPrefix(path, ".")
+
+		if index >= 0 {
+			l, ok := v.([]any)
+			if !ok || index >= len(l) {
+				return nil, fmt.Errorf("no element [%d]", index)
+			}
+			v = l[index]
+			continue
+		}
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("no key %q", key)
+		}
+		v, ok = m[key]
+		if !ok {
+			return nil, fmt.Errorf("no key %q", key)
+		}
+	}
+	return v, nil
+}
+
+func assertValue(t testing.TB, res *ezcx.WebhookResponse, what string, got, want any) bool {
+	t.Helper()
+	wv, err := structpb.NewValue(want)
+	if err != nil {
+		t.Fatalf("%s: can't compare with %T: %v", what, want, err)
+	}
+	if !reflect.DeepEqual(got, wv.AsInterface()) {
+		fail(t, res, "%s is %s, want %s", what, jsonString(got), jsonString(wv.AsInterface()))
+		return false
+	}
+	return true
+}
+
+func fail(t testing.TB, res *ezcx.WebhookResponse, format string, args ...any) {
+	t.Helper()
+	b, err := canonicalJSON(&res.WebhookResponse)
+	if err != nil {
+		t.Errorf(format+"\nresponse: %v", append(args, err)...)
+		return
+	}
+	t.Errorf(format+"\nresponse:\n%s", append(args, b)...)
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcxtest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/googlecloudplatform/ezcx"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// recorder captures failures instead of failing the enclosing test.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func testResponse(t *testing.T) *ezcx.WebhookResponse {
+	req := NewRequest().Tag("order").MustBuild()
+	res := req.InitializeResponse()
+	res.AddTextResponse("Thanks!", "Your order number is 1234.")
+	err := res.AddSessionParameters(map[string]any{"count": 2, "stale": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = res.AddPayload(map[string]any{"order": map[string]any{"items": []any{map[string]any{"sku": "A1"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Transition = &cx.WebhookResponse_TargetPage{TargetPage: "projects/p/locations/l/agents/a/flows/f/pages/confirm"}
+	res.PageInfo = &cx.PageInfo{FormInfo: &cx.PageInfo_FormInfo{ParameterInfo: []*cx.PageInfo_FormInfo_ParameterInfo{
+		{DisplayName: "zip", State: Invalid},
+	}}}
+	return res
+}
+
+func TestAssertPass(t *testing.T) {
+	res := testResponse(t)
+	AssertText(t, res, "Thanks!", regexp.MustCompile(`^Your order number is \d+\.$`))
+	AssertSessionParam(t, res, "count", 2)
+	AssertParamDeleted(t, res, "stale")
+	AssertTransition(t, res, "confirm")
+	AssertTransition(t, res, res.GetTargetPage())
+	AssertFormParamState(t, res, "zip", Invalid)
+	AssertPayloadPath(t, res, "$.order.items[0].sku", "A1")
+	AssertPayloadPath(t, res, `order["items"][0]`, map[string]any{"sku": "A1"})
+	AssertNoMessages(t, ezcx.NewWebhookResponse())
+}
+
+func TestAssertFail(t *testing.T) {
+	res := testResponse(t)
+	tests := []struct {
+		name   string
+		assert func(testing.TB) bool
+		want   string
+	}{
+		{"text", func(t testing.TB) bool { return AssertText(t, res, "Thanks!", "Bye.") }, "- Bye.\n+ Your order number is 1234."},
+		{"regexp", func(t testing.TB) bool { return AssertText(t, res, "Thanks!", regexp.MustCompile(`^\d+$`)) }, `- /^\d+$/`},
+		{"no messages", func(t testing.TB) bool { return AssertNoMessages(t, res) }, "got 1 messages"},
+		{"param", func(t testing.TB) bool { return AssertSessionParam(t, res, "count", 3) }, `"count" is 2, want 3`},
+		{"param missing", func(t testing.TB) bool { return AssertSessionParam(t, res, "nope", 1) }, "not set"},
+		{"param deleted", func(t testing.TB) bool { return AssertSessionParam(t, res, "stale", 1) }, "is deleted"},
+		{"not deleted", func(t testing.TB) bool { return AssertParamDeleted(t, res, "count") }, "is 2, want null"},
+		{"transition", func(t testing.TB) bool { return AssertTransition(t, res, "firm") }, "want firm"},
+		{"form state", func(t testing.TB) bool { return AssertFormParamState(t, res, "zip", Filled) }, "is INVALID, want FILLED"},
+		{"payload", func(t testing.TB) bool { return AssertPayloadPath(t, res, "order.items[1]", "x") }, "no element [1]"},
+	}
+	for _, tc := range tests {
+		r := &recorder{TB: t}
+		if tc.assert(r) {
+			t.Errorf("%s: assertion passed, want failure", tc.name)
+			continue
+		}
+		if len(r.errs) != 1 || !strings.Contains(r.errs[0], tc.want) {
+			t.Errorf("%s: errors = %q, want one containing %q", tc.name, r.errs, tc.want)
+			continue
+		}
+		// The whole response is printed with sorted keys.
+		if !strings.Contains(r.errs[0], `"targetPage": "projects/p/locations/l/agents/a/flows/f/pages/confirm"`) {
+			t.Errorf("%s: response missing from failure:\n%s", tc.name, r.errs[0])
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	v := map[string]any{"a.b": []any{1.0, map[string]any{"c": "d"}}}
+	got, err := Lookup(v, `$["a.b"][1].c`)
+	if err != nil || got != "d" {
+		t.Errorf("Lookup = %v, %v; want d", got, err)
+	}
+	_, err = Lookup(v, "missing")
+	if err == nil {
+		t.Error("Lookup of a missing key succeeded")
+	}
+	_, err = Lookup(structpb.NewNullValue().AsInterface(), "x")
+	if err == nil {
+		t.Error("Lookup into null succeeded")
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcxtest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/googlecloudplatform/ezcx"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Special transition targets.
+const (
+	StartPage    = "START_PAGE"
+	EndSession   = "END_SESSION"
+	EndFlow      = "END_FLOW"
+	CurrentPage  = "CURRENT_PAGE"
+	PreviousPage = "PREVIOUS_PAGE"
+)
+
+var ErrSessionEnded = errors.New("ezcxtest: the session has ended")
+
+// Turn is a single webhook call made by a Conversation.
+type Turn struct {
+	Request  *ezcx.WebhookRequest
+	Response *ezcx.WebhookResponse
+	Err      error
+}
+
+// Conversation simulates a Dialogflow CX session across turns.  Each
+// WebhookResponse is applied back onto the session the way CX does it:
+// session parameters are merged, with null deleting a parameter; form
+// parameter states and values from the response's page info replace the
+// current ones; and the target page or flow becomes the current page,
+// which starts with an empty form.  A target flow is called like CX does:
+// its END_FLOW returns to the page that targeted it, and only END_FLOW in
+// the starting flow ends the session.
+type Conversation struct {
+	// Handlers maps fulfillment tags to the handlers called for them.
+	Handlers map[string]ezcx.HandlerFunc
+	// PageNames maps page IDs to display names.  Pages without an entry
+	// use their ID as their display name.
+	PageNames map[string]string
+	Turns     []*Turn
+
+	session  string
+	language string
+	params   map[string]any
+	page     string
+	pageName string
+	pages    []string
+	calls    []flowCall // flows that called the current one
+	form     []*cx.PageInfo_FormInfo_ParameterInfo
+	ended    bool
+}
+
+// flowCall is where END_FLOW returns to.
+type flowCall struct {
+	page     string
+	pageName string
+	pages    []string
+}
+
+// NewConversation starts a conversation.  The session name, language,
+// starting page, form and session parameters are taken from b; if b is nil,
+// NewRequest's defaults are used.
+func NewConversation(b *RequestBuilder, handlers map[string]ezcx.HandlerFunc) (*Conversation, error) {
+	if b == nil {
+		b = NewRequest()
+	}
+	req, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	c := &Conversation{
+		Handlers:  handlers,
+		PageNames: make(map[string]string),
+		session:   req.GetSessionInfo().GetSession(),
+		language:  req.GetLanguageCode(),
+		params:    make(map[string]any),
+		page:      req.GetPageInfo().GetCurrentPage(),
+		pageName:  req.GetPageInfo().GetDisplayName(),
+		form:      req.GetPageInfo().GetFormInfo().GetParameterInfo(),
+	}
+	for k, v := range req.GetSessionInfo().GetParameters() {
+		c.params[k] = v.AsInterface()
+	}
+	return c, nil
+}
+
+// Session returns the session name.
+func (c *Conversation) Session() string {
+	return c.session
+}
+
+// Params returns a copy of the current session parameters.
+func (c *Conversation) Params() map[string]any {
+	m := make(map[string]any, len(c.params))
+	for k, v := range c.params {
+		m[k] = v
+	}
+	return m
+}
+
+func (c *Conversation) Param(name string) (any, bool) {
+	v, ok := c.params[name]
+	return v, ok
+}
+
+// SetParam sets a session parameter as if the agent had collected it.
+func (c *Conversation) SetParam(name string, value any) {
+	c.params[name] = value
+}
+
+// Page returns the resource name of the current page.
+func (c *Conversation) Page() string {
+	return c.page
+}
+
+// FormState returns the state of the named form parameter on the current
+// page.
+func (c *Conversation) FormState(name string) (cx.PageInfo_FormInfo_ParameterInfo_ParameterState, bool) {
+	p := c.formParam(name)
+	if p == nil {
+		return 0, false
+	}
+	return p.State, true
+}
+
+// FillForm fills a form parameter on the current page as if the agent had
+// just collected it from the user; it's also set as a session parameter.
+func (c *Conversation) FillForm(name string, value any) error {
+	pv, err := structpb.NewValue(value)
+	if err != nil {
+		return err
+	}
+	p := c.formParam(name)
+	if p == nil {
+		p = &cx.PageInfo_FormInfo_ParameterInfo{DisplayName: name}
+		c.form = append(c.form, p)
+	}
+	p.State = Filled
+	p.Value = pv
+	p.JustCollected = true
+	c.params[name] = value
+	return nil
+}
+
+// Ended reports whether a response transitioned to END_SESSION, or to
+// END_FLOW in the starting flow.
+func (c *Conversation) Ended() bool {
+	return c.ended
+}
+
+// Say calls the handler for tag with the user's text input.
+func (c *Conversation) Say(tag, text string) (*ezcx.WebhookResponse, error) {
+	return c.Call(tag, func(b *RequestBuilder) { b.Text(text) })
+}
+
+// Event calls the handler for tag as if triggered by event.
+func (c *Conversation) Event(tag, event string) (*ezcx.WebhookResponse, error) {
+	return c.Call(tag, func(b *RequestBuilder) { b.TriggerEvent(event) })
+}
+
+// Call calls the handler for tag.  The conversation sets the session,
+// language, session parameters, page and form; customize may set anything
+// else e.g. the query, intent or payload.  If the handler returns an error,
+// its response isn't applied to the session, just like CX.
+func (c *Conversation) Call(tag string, customize func(*RequestBuilder)) (*ezcx.WebhookResponse, error) {
+	if c.ended {
+		return nil, ErrSessionEnded
+	}
+	h, ok := c.Handlers[tag]
+	if !ok {
+		return nil, fmt.Errorf("ezcxtest: no handler for tag %q", tag)
+	}
+	b := NewRequest().Session(c.session).Language(c.language).SessionParams(c.params).Tag(tag)
+	if customize != nil {
+		customize(b)
+	}
+	req, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	req.PageInfo.CurrentPage = c.page
+	req.PageInfo.DisplayName = c.pageName
+	req.PageInfo.FormInfo = nil
+	if len(c.form) > 0 {
+		form := &cx.PageInfo_FormInfo{}
+		for _, p := range c.form {
+			form.ParameterInfo = append(form.ParameterInfo, proto.Clone(p).(*cx.PageInfo_FormInfo_ParameterInfo))
+		}
+		req.PageInfo.FormInfo = form
+	}
+
+	res := req.InitializeResponse()
+	err = h(res, req)
+	c.Turns = append(c.Turns, &Turn{Request: req, Response: res, Err: err})
+	for _, p := range c.form {
+		p.JustCollected = false
+	}
+	if err != nil {
+		return res, err
+	}
+	c.apply(res)
+	return res, nil
+}
+
+func (c *Conversation) apply(res *ezcx.WebhookResponse) {
+	for k, v := range res.GetSessionInfo().GetParameters() {
+		if _, isNull := v.GetKind().(*structpb.Value_NullValue); isNull {
+			delete(c.params, k)
+			continue
+		}
+		c.params[k] = v.AsInterface()
+	}
+
+	for _, rp := range res.GetPageInfo().GetFormInfo().GetParameterInfo() {
+		p := c.formParam(rp.GetDisplayName())
+		if p == nil {
+			p = &cx.PageInfo_FormInfo_ParameterInfo{DisplayName: rp.GetDisplayName(), Required: rp.GetRequired()}
+			c.form = append(c.form, p)
+		}
+		p.State = rp.GetState()
+		switch {
+		case p.State == Invalid || p.State == Empty:
+			// The parameter will be collected again.
+			p.Value = nil
+			delete(c.params, p.DisplayName)
+		case rp.Value != nil:
+			p.Value = rp.Value
+			c.params[p.DisplayName] = rp.Value.AsInterface()
+		}
+	}
+
+	if target := res.GetTargetFlow(); target != "" {
+		c.calls = append(c.calls, flowCall{c.page, c.pageName, c.pages})
+		c.pages = nil
+		c.enter(target+"/pages/"+StartPage, StartPage)
+	}
+	if target := res.GetTargetPage(); target != "" {
+		c.transition(target)
+	}
+}
+
+func (c *Conversation) transition(target string) {
+	id := target[strings.LastIndex(target, "/")+1:]
+	switch id {
+	case CurrentPage:
+		return
+	case EndSession:
+		c.ended = true
+		return
+	case EndFlow:
+		if len(c.calls) == 0 {
+			c.ended = true
+			return
+		}
+		call := c.calls[len(c.calls)-1]
+		c.calls = c.calls[:len(c.calls)-1]
+		c.page, c.pageName, c.pages = call.page, call.pageName, call.pages
+		c.form = nil
+		return
+	case PreviousPage:
+		if len(c.pages) == 0 {
+			return
+		}
+		target = c.pages[len(c.pages)-1]
+		c.pages = c.pages[:len(c.pages)-1]
+		id = target[strings.LastIndex(target, "/")+1:]
+	default:
+		c.pages = append(c.pages, c.page)
+	}
+	c.enter(target, id)
+}
+
+// enter makes the page target, whose ID is id, the current page.
+func (c *Conversation) enter(target, id string) {
+	c.page = target
+	c.pageName = id
+	if name, ok := c.PageNames[id]; ok {
+		c.pageName = name
+	}
+	c.form = nil
+}
+
+func (c *Conversation) formParam(name string) *cx.PageInfo_FormInfo_ParameterInfo {
+	for _, p := range c.form {
+		if p.GetDisplayName() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Step is a single scripted turn for Run.
+type Step struct {
+	Tag   string
+	Text  string
+	Event string
+	// Params are set as session parameters before the turn.
+	Params map[string]any
+	// Form parameters are filled, as with FillForm, before the turn.
+	Form map[string]any
+	// Check, if set, is called with the turn's response.
+	Check func(testing.TB, *ezcx.WebhookResponse)
+}
+
+// Run plays the steps in order, failing t if any handler returns an error.
+func (c *Conversation) Run(t testing.TB, steps ...Step) {
+	t.Helper()
+	for i, step := range steps {
+		for k, v := range step.Params {
+			c.SetParam(k, v)
+		}
+		for k, v := range step.Form {
+			err := c.FillForm(k, v)
+			if err != nil {
+				t.Fatalf("turn %d (%s): %v", i+1, step.Tag, err)
+			}
+		}
+		res, err := c.Call(step.Tag, func(b *RequestBuilder) {
+			if step.Text != "" {
+				b.Text(step.Text)
+			}
+			if step.Event != "" {
+				b.TriggerEvent(step.Event)
+			}
+		})
+		if err != nil {
+			t.Fatalf("turn %d (%s): %v", i+1, step.Tag, err)
+		}
+		if step.Check != nil {
+			step.Check(t, res)
+		}
+	}
+}
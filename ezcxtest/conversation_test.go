@@ -0,0 +1,180 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcxtest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/googlecloudplatform/ezcx"
+)
+
+func orderHandlers() map[string]ezcx.HandlerFunc {
+	return map[string]ezcx.HandlerFunc{
+		"start": func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+			res.AddTextResponse("What would you like?")
+			res.Transition = &cx.WebhookResponse_TargetPage{TargetPage: req.GetPageInfo().GetCurrentPage()[:strings.LastIndex(req.GetPageInfo().GetCurrentPage(), "/")] + "/order"}
+			return res.AddSessionParameters(map[string]any{"cart": []any{}, "greeted": true})
+		},
+		"validate-size": func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+			size := req.GetPageFormParameters()["size"]
+			if size == "huge" {
+				res.AddTextResponse("We don't have that size.")
+				res.PageInfo = &cx.PageInfo{FormInfo: &cx.PageInfo_FormInfo{ParameterInfo: []*cx.PageInfo_FormInfo_ParameterInfo{
+					{DisplayName: "size", State: Invalid},
+				}}}
+				return nil
+			}
+			res.AddTextResponse(fmt.Sprintf("One %s coffee.", size))
+			return res.AddSessionParameters(map[string]any{"greeted": nil})
+		},
+		"checkout": func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+			if _, ok := req.GetSessionParameter("size"); !ok {
+				return errors.New("no size")
+			}
+			res.Transition = &cx.WebhookResponse_TargetPage{TargetPage: EndSession}
+			return nil
+		},
+	}
+}
+
+func TestConversation(t *testing.T) {
+	c, err := NewConversation(NewRequest().SessionID("s1"), orderHandlers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.PageNames["order"] = "Order"
+
+	res, err := c.Say("start", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertText(t, res, "What would you like?")
+	if !strings.HasSuffix(c.Page(), "/pages/order") {
+		t.Errorf("page = %s, want .../pages/order", c.Page())
+	}
+
+	// The agent collects an invalid size, which the webhook invalidates.
+	err = c.FillForm("size", "huge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Say("validate-size", "huge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := c.FormState("size"); state != Invalid {
+		t.Errorf("size state = %s, want INVALID", state)
+	}
+	if _, ok := c.Param("size"); ok {
+		t.Error("invalidated size is still a session parameter")
+	}
+	req := c.Turns[1].Request
+	if req.GetPageInfo().GetDisplayName() != "Order" || !req.GetPageInfo().GetFormInfo().GetParameterInfo()[0].JustCollected {
+		t.Errorf("turn 2 page info = %v", req.GetPageInfo())
+	}
+
+	// Checking out fails without a size, and the failed turn isn't applied.
+	_, err = c.Say("checkout", "done")
+	if err == nil {
+		t.Fatal("checkout without a size succeeded")
+	}
+
+	c.Run(t,
+		Step{Tag: "validate-size", Text: "large", Form: map[string]any{"size": "large"}, Check: func(t testing.TB, res *ezcx.WebhookResponse) {
+			AssertText(t, res, "One large coffee.")
+		}},
+		Step{Tag: "checkout", Text: "done"},
+	)
+	if _, ok := c.Param("greeted"); ok {
+		t.Error("greeted was set to null but not deleted")
+	}
+	if _, ok := c.Param("cart"); !ok {
+		t.Error("cart set on turn 1 is missing")
+	}
+	if !c.Ended() {
+		t.Error("conversation didn't end")
+	}
+	_, err = c.Say("start", "again")
+	if !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("err = %v, want ErrSessionEnded", err)
+	}
+	if got := c.Turns[len(c.Turns)-1].Request.GetSessionInfo().GetSession(); !strings.HasSuffix(got, "/sessions/s1") {
+		t.Errorf("session = %s", got)
+	}
+}
+
+func TestConversationUnknownTag(t *testing.T) {
+	c, err := NewConversation(nil, orderHandlers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Say("nope", "hi")
+	if err == nil {
+		t.Error("unknown tag succeeded")
+	}
+}
+
+func TestConversationSubFlow(t *testing.T) {
+	handlers := map[string]ezcx.HandlerFunc{
+		"call": func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+			page := req.GetPageInfo().GetCurrentPage()
+			res.Transition = &cx.WebhookResponse_TargetFlow{TargetFlow: page[:strings.Index(page, "/flows/")] + "/flows/payment"}
+			return nil
+		},
+		"end-flow": func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+			page := req.GetPageInfo().GetCurrentPage()
+			res.Transition = &cx.WebhookResponse_TargetPage{TargetPage: page[:strings.LastIndex(page, "/")+1] + EndFlow}
+			return nil
+		},
+	}
+	c, err := NewConversation(nil, handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := c.Page()
+
+	_, err = c.Say("call", "pay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(c.Page(), "/flows/payment/pages/"+StartPage) {
+		t.Errorf("page = %s, want the payment flow's start page", c.Page())
+	}
+
+	// END_FLOW in the called flow returns to the calling page.
+	_, err = c.Say("end-flow", "paid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Ended() {
+		t.Fatal("END_FLOW in a called flow ended the session")
+	}
+	if c.Page() != start {
+		t.Errorf("page = %s, want %s", c.Page(), start)
+	}
+
+	// END_FLOW in the starting flow ends the session.
+	_, err = c.Say("end-flow", "bye")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Ended() {
+		t.Error("END_FLOW in the starting flow didn't end the session")
+	}
+}
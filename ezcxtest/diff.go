@@ -0,0 +1,104 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// canonicalJSON returns m as indented protojson with map keys sorted, so
+// the output is stable across runs.
+func canonicalJSON(m proto.Message) ([]byte, error) {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	err = json.Unmarshal(b, &v)
+	if err != nil {
+		return nil, err
+	}
+	return marshalIndent(v)
+}
+
+// marshalIndent is json.MarshalIndent without HTML escaping, followed by a
+// newline.
+func marshalIndent(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lineDiff returns a unified-style diff of want and got: lines only in want
+// are prefixed with "-", lines only in got with "+" and common lines with a
+// space.
+func lineDiff(want, got []string) string {
+	// Longest common subsequence table; fine for the sizes of webhook
+	// responses.
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			sb.WriteString("  " + want[i] + "\n")
+			i++
+			j++
+		case i < len(want) && (j == len(got) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + want[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + got[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
+
+func lines(b []byte) []string {
+	return strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+}
+
+func jsonString(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
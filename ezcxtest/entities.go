@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcxtest
+
+import "time"
+
+// Presets for common system entities.  Each returns the value in the shape
+// Dialogflow CX sends it in parameters, so it can be passed to SessionParam,
+// FormParam or IntentParam.
+
+// SysDate returns an @sys.date value.
+func SysDate(year int, month time.Month, day int) map[string]any {
+	return map[string]any{
+		"year":  float64(year),
+		"month": float64(month),
+		"day":   float64(day),
+	}
+}
+
+// SysTime returns an @sys.time value.
+func SysTime(hours, minutes, seconds int) map[string]any {
+	return map[string]any{
+		"hours":   float64(hours),
+		"minutes": float64(minutes),
+		"seconds": float64(seconds),
+		"nanos":   float64(0),
+	}
+}
+
+// SysDateTime returns an @sys.date-time value.
+func SysDateTime(t time.Time) map[string]any {
+	return map[string]any{
+		"year":    float64(t.Year()),
+		"month":   float64(t.Month()),
+		"day":     float64(t.Day()),
+		"hours":   float64(t.Hour()),
+		"minutes": float64(t.Minute()),
+		"seconds": float64(t.Second()),
+		"nanos":   float64(t.Nanosecond()),
+	}
+}
+
+// SysNumber returns an @sys.number value.
+func SysNumber(n float64) float64 {
+	return n
+}
+
+// SysUnitCurrency returns an @sys.unit-currency value; currency is an ISO
+// 4217 code.
+func SysUnitCurrency(amount float64, currency string) map[string]any {
+	return map[string]any{
+		"amount":   amount,
+		"currency": currency,
+	}
+}
+
+// SysDuration returns an @sys.duration value e.g. SysDuration(5, "min").
+func SysDuration(amount float64, unit string) map[string]any {
+	return map[string]any{
+		"amount": amount,
+		"unit":   unit,
+	}
+}
+
+// SysAge returns an @sys.age value.
+func SysAge(years float64) map[string]any {
+	return map[string]any{
+		"amount": years,
+		"unit":   "yr",
+	}
+}
+
+// SysPerson returns an @sys.person value.
+func SysPerson(name string) map[string]any {
+	return map[string]any{
+		"name": name,
+	}
+}
+
+// SysPhoneNumber returns an @sys.phone-number value.
+func SysPhoneNumber(number string) string {
+	return number
+}
+
+// SysEmail returns an @sys.email value.
+func SysEmail(email string) string {
+	return email
+}
+
+// SysGeoCity returns an @sys.geo-city value.
+func SysGeoCity(city string) string {
+	return city
+}
+
+// SysLocation returns an @sys.location value.  Empty fields are omitted.
+func SysLocation(street, city, adminArea, zipCode, country string) map[string]any {
+	loc := make(map[string]any)
+	for k, v := range map[string]string{
+		"street-address": street,
+		"city":           city,
+		"admin-area":     adminArea,
+		"zip-code":       zipCode,
+		"country":        country,
+	} {
+		if v != "" {
+			loc[k] = v
+		}
+	}
+	return loc
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcxtest
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/googlecloudplatform/ezcx"
+)
+
+// UpdateEnv is the environment variable that, set to 1, makes Golden
+// (re)write golden files instead of comparing them.
+const UpdateEnv = "EZCX_UPDATE_GOLDEN"
+
+// Update makes Golden (re)write golden files, as do the -update flag and
+// setting UpdateEnv.  The flag is registered unless another package already
+// defined one, in which case that flag is used; a test package declaring
+// its own -update flag after importing ezcxtest should set Update instead.
+var Update bool
+
+func init() {
+	if flag.Lookup("update") == nil {
+		flag.BoolVar(&Update, "update", false, "update golden files")
+	}
+}
+
+func update() bool {
+	if f := flag.Lookup("update"); f != nil && f.Value.String() == "true" {
+		return true
+	}
+	return Update || os.Getenv(UpdateEnv) == "1"
+}
+
+// Masked replaces masked values in golden files.
+const Masked = "<masked>"
+
+// DefaultMasks are applied by Golden in addition to the masks provided.
+// The session name is masked since NewRequest generates a random session
+// ID.
+var DefaultMasks = []string{"sessionInfo.session"}
+
+// Golden compares res with the golden file testdata/<name>.golden.  When
+// run with -update, Update is set or EZCX_UPDATE_GOLDEN=1, the golden file
+// is (re)written instead.
+//
+// Responses are written as indented protojson with sorted keys.  masks are
+// dotted protojson paths, e.g. "payload.order.id", whose values are
+// replaced with Masked before writing or comparing; "*" matches any single
+// path segment and list elements are addressed by index.
+func Golden(t testing.TB, name string, res *ezcx.WebhookResponse, masks ...string) {
+	t.Helper()
+	got, err := goldenJSON(res, append(slices.Clip(DefaultMasks), masks...))
+	if err != nil {
+		t.Fatalf("golden %s: %v", name, err)
+	}
+	path := filepath.Join("testdata", name+".golden")
+	if update() {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, got, 0644)
+		}
+		if err != nil {
+			t.Fatalf("golden %s: %v", name, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("golden %s: %s does not exist; run the test with %s=1 to create it", name, path, UpdateEnv)
+	}
+	if err != nil {
+		t.Fatalf("golden %s: %v", name, err)
+	}
+	if string(want) != string(got) {
+		t.Errorf("golden %s: response differs from %s (-want +got):\n%s", name, path, lineDiff(lines(want), lines(got)))
+	}
+}
+
+func goldenJSON(res *ezcx.WebhookResponse, masks []string) ([]byte, error) {
+	b, err := canonicalJSON(&res.WebhookResponse)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	err = json.Unmarshal(b, &v)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range masks {
+		v = mask(v, strings.Split(m, "."))
+	}
+	return marshalIndent(v)
+}
+
+// mask replaces the values at path in v with Masked.
+func mask(v any, path []string) any {
+	if len(path) == 0 {
+		return Masked
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if path[0] == "*" || path[0] == k {
+				v[k] = mask(e, path[1:])
+			}
+		}
+	case []any:
+		for i, e := range v {
+			if path[0] == "*" || path[0] == strconv.Itoa(i) {
+				v[i] = mask(e, path[1:])
+			}
+		}
+	}
+	return v
+}
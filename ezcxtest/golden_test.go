@@ -0,0 +1,59 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcxtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGolden(t *testing.T) {
+	res := testResponse(t)
+	err := res.AddPayload(map[string]any{"requestId": uuid.New().String(), "b": 1, "a": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Golden(t, "order", res, "payload.requestId")
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	if update() {
+		t.Skip("not comparing while updating")
+	}
+	res := testResponse(t)
+	res.AddTextResponse("extra")
+	err := res.AddPayload(map[string]any{"requestId": "x", "b": 1, "a": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &recorder{TB: t}
+	Golden(r, "order", res, "payload.requestId")
+	if len(r.errs) != 1 || !strings.Contains(r.errs[0], `+             "extra"`) {
+		t.Errorf("errors = %q, want a diff adding a text message", r.errs)
+	}
+}
+
+func TestMask(t *testing.T) {
+	v := map[string]any{"a": []any{map[string]any{"id": 1.0}, map[string]any{"id": 2.0}}, "b": "keep"}
+	mask(v, []string{"a", "*", "id"})
+	if got := v["a"].([]any)[1].(map[string]any)["id"]; got != Masked {
+		t.Errorf("a.1.id = %v, want %s", got, Masked)
+	}
+	if v["b"] != "keep" {
+		t.Errorf("b = %v, want keep", v["b"])
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ezcxtest provides utilities for testing ezcx handlers, in the
+// spirit of net/http/httptest.
+package ezcxtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/google/uuid"
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/internal/structpbutil"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Defaults used by NewRequest.  The default flow ID is the ID Dialogflow CX
+// gives every agent's Default Start Flow.
+const (
+	DefaultProject         = "ezcx-test"
+	DefaultLocation        = "global"
+	DefaultAgent           = "ezcx-test-agent"
+	DefaultFlow            = "00000000-0000-0000-0000-000000000000"
+	DefaultPage            = "START_PAGE"
+	DefaultPageDisplayName = "Start Page"
+	DefaultLanguageCode    = "en"
+)
+
+// Form parameter states, re-exported for convenience.
+const (
+	Empty   = cx.PageInfo_FormInfo_ParameterInfo_EMPTY
+	Invalid = cx.PageInfo_FormInfo_ParameterInfo_INVALID
+	Filled  = cx.PageInfo_FormInfo_ParameterInfo_FILLED
+)
+
+type formParam struct {
+	name          string
+	value         any
+	state         cx.PageInfo_FormInfo_ParameterInfo_ParameterState
+	required      bool
+	justCollected bool
+}
+
+type intentParam struct {
+	original string
+	resolved any
+}
+
+// RequestBuilder builds WebhookRequests for tests.  Setters return the
+// builder so calls can be chained; Build assembles the WebhookRequest.
+type RequestBuilder struct {
+	project     string
+	location    string
+	agent       string
+	environment string
+	sessionID   string
+	session     string
+	flow        string
+	page        string
+	pageName    string
+
+	detectIntentResponseID string
+	query                  func(*cx.WebhookRequest)
+	languageCode           string
+	tag                    string
+
+	intent           string
+	intentName       string
+	intentConfidence float32
+	intentParams     map[string]intentParam
+
+	sessionParams map[string]any
+	formParams    []*formParam
+	payload       map[string]any
+	messages      []*cx.ResponseMessage
+	sentiment     *cx.WebhookRequest_SentimentAnalysisResult
+	ctx           context.Context
+}
+
+// NewRequest returns a RequestBuilder with realistic defaults: a full
+// projects/.../sessions/... session name with a random session ID, a random
+// detectIntentResponseId, the Start Page of the Default Start Flow as the
+// current page and English as the language.
+func NewRequest() *RequestBuilder {
+	return &RequestBuilder{
+		project:                DefaultProject,
+		location:               DefaultLocation,
+		agent:                  DefaultAgent,
+		sessionID:              uuid.New().String(),
+		flow:                   DefaultFlow,
+		page:                   DefaultPage,
+		pageName:               DefaultPageDisplayName,
+		detectIntentResponseID: uuid.New().String(),
+		languageCode:           DefaultLanguageCode,
+		intentParams:           make(map[string]intentParam),
+		sessionParams:          make(map[string]any),
+		payload:                make(map[string]any),
+	}
+}
+
+func (b *RequestBuilder) Project(id string) *RequestBuilder {
+	b.project = id
+	return b
+}
+
+func (b *RequestBuilder) Location(id string) *RequestBuilder {
+	b.location = id
+	return b
+}
+
+func (b *RequestBuilder) Agent(id string) *RequestBuilder {
+	b.agent = id
+	return b
+}
+
+// Environment adds an environments/<id> segment to the session name.
+func (b *RequestBuilder) Environment(id string) *RequestBuilder {
+	b.environment = id
+	return b
+}
+
+func (b *RequestBuilder) SessionID(id string) *RequestBuilder {
+	b.sessionID = id
+	return b
+}
+
+// Session sets the full session name, overriding the name built from the
+// project, location, agent, environment and session ID.
+func (b *RequestBuilder) Session(name string) *RequestBuilder {
+	b.session = name
+	return b
+}
+
+func (b *RequestBuilder) Flow(id string) *RequestBuilder {
+	b.flow = id
+	return b
+}
+
+// Page sets the current page's ID and display name.
+func (b *RequestBuilder) Page(id, displayName string) *RequestBuilder {
+	b.page = id
+	b.pageName = displayName
+	return b
+}
+
+func (b *RequestBuilder) DetectIntentResponseID(id string) *RequestBuilder {
+	b.detectIntentResponseID = id
+	return b
+}
+
+func (b *RequestBuilder) Tag(tag string) *RequestBuilder {
+	b.tag = tag
+	return b
+}
+
+func (b *RequestBuilder) Language(code string) *RequestBuilder {
+	b.languageCode = code
+	return b
+}
+
+// Text sets the user's text input as the query.
+func (b *RequestBuilder) Text(text string) *RequestBuilder {
+	b.query = func(req *cx.WebhookRequest) {
+		req.Query = &cx.WebhookRequest_Text{Text: text}
+	}
+	return b
+}
+
+// Transcript sets a speech transcript as the query.
+func (b *RequestBuilder) Transcript(transcript string) *RequestBuilder {
+	b.query = func(req *cx.WebhookRequest) {
+		req.Query = &cx.WebhookRequest_Transcript{Transcript: transcript}
+	}
+	return b
+}
+
+// TriggerIntent sets an intent resource name as the query.
+func (b *RequestBuilder) TriggerIntent(intent string) *RequestBuilder {
+	b.query = func(req *cx.WebhookRequest) {
+		req.Query = &cx.WebhookRequest_TriggerIntent{TriggerIntent: intent}
+	}
+	return b
+}
+
+// TriggerEvent sets an event name as the query.
+func (b *RequestBuilder) TriggerEvent(event string) *RequestBuilder {
+	b.query = func(req *cx.WebhookRequest) {
+		req.Query = &cx.WebhookRequest_TriggerEvent{TriggerEvent: event}
+	}
+	return b
+}
+
+// Intent sets the matched intent.  id is the intent's ID; the full resource
+// name is built from the agent.
+func (b *RequestBuilder) Intent(id, displayName string, confidence float32) *RequestBuilder {
+	b.intent = id
+	b.intentName = displayName
+	b.intentConfidence = confidence
+	return b
+}
+
+// IntentParam adds a parameter of the matched intent.
+func (b *RequestBuilder) IntentParam(name, original string, resolved any) *RequestBuilder {
+	b.intentParams[name] = intentParam{original: original, resolved: resolved}
+	return b
+}
+
+func (b *RequestBuilder) SessionParam(name string, value any) *RequestBuilder {
+	b.sessionParams[name] = value
+	return b
+}
+
+func (b *RequestBuilder) SessionParams(params map[string]any) *RequestBuilder {
+	for k, v := range params {
+		b.sessionParams[k] = v
+	}
+	return b
+}
+
+// FormParam adds a filled form parameter to the current page.
+func (b *RequestBuilder) FormParam(name string, value any) *RequestBuilder {
+	return b.FormParamState(name, value, Filled)
+}
+
+// FormParamState adds a form parameter in the given state; value may be nil
+// for Empty parameters.
+func (b *RequestBuilder) FormParamState(name string, value any, state cx.PageInfo_FormInfo_ParameterInfo_ParameterState) *RequestBuilder {
+	b.formParams = append(b.formParams, &formParam{name: name, value: value, state: state})
+	return b
+}
+
+// Required marks the named form parameters as required.
+func (b *RequestBuilder) Required(names ...string) *RequestBuilder {
+	b.eachFormParam(names, func(p *formParam) { p.required = true })
+	return b
+}
+
+// JustCollected marks the named form parameters as collected this turn.
+func (b *RequestBuilder) JustCollected(names ...string) *RequestBuilder {
+	b.eachFormParam(names, func(p *formParam) { p.justCollected = true })
+	return b
+}
+
+func (b *RequestBuilder) eachFormParam(names []string, f func(*formParam)) {
+	for _, name := range names {
+		for _, p := range b.formParams {
+			if p.name == name {
+				f(p)
+			}
+		}
+	}
+}
+
+func (b *RequestBuilder) Payload(name string, value any) *RequestBuilder {
+	b.payload[name] = value
+	return b
+}
+
+// Message adds a text ResponseMessage, as if it were produced by a
+// fulfillment earlier in the turn.
+func (b *RequestBuilder) Message(txts ...string) *RequestBuilder {
+	b.messages = append(b.messages, &cx.ResponseMessage{
+		Message: &cx.ResponseMessage_Text_{Text: &cx.ResponseMessage_Text{Text: txts}},
+	})
+	return b
+}
+
+func (b *RequestBuilder) Sentiment(score, magnitude float32) *RequestBuilder {
+	b.sentiment = &cx.WebhookRequest_SentimentAnalysisResult{Score: score, Magnitude: magnitude}
+	return b
+}
+
+// Context sets the context returned by (*WebhookRequest).Context; it
+// defaults to context.Background.
+func (b *RequestBuilder) Context(ctx context.Context) *RequestBuilder {
+	b.ctx = ctx
+	return b
+}
+
+func (b *RequestBuilder) agentName() ezcx.AgentName {
+	return ezcx.AgentName{Project: b.project, Location: b.location, Agent: b.agent}
+}
+
+func (b *RequestBuilder) sessionName() string {
+	if b.session != "" {
+		return b.session
+	}
+	return ezcx.SessionName{AgentName: b.agentName(), Environment: b.environment, Session: b.sessionID}.String()
+}
+
+// Build assembles the WebhookRequest.  An error is returned if any of the
+// provided values can't be converted to a protobuf Value.
+func (b *RequestBuilder) Build() (*ezcx.WebhookRequest, error) {
+	req := ezcx.NewWebhookRequest()
+	req.DetectIntentResponseId = b.detectIntentResponseID
+	req.LanguageCode = b.languageCode
+	if b.query != nil {
+		b.query(&req.WebhookRequest)
+	}
+	if b.tag != "" {
+		req.FulfillmentInfo = &cx.WebhookRequest_FulfillmentInfo{Tag: b.tag}
+	}
+
+	params, err := structpbutil.ToProtoMap(b.sessionParams)
+	if err != nil {
+		return nil, fmt.Errorf("session parameters: %w", err)
+	}
+	req.SessionInfo = &cx.SessionInfo{Session: b.sessionName(), Parameters: params}
+
+	req.PageInfo = &cx.PageInfo{
+		CurrentPage: ezcx.PageName{FlowName: ezcx.FlowName{AgentName: b.agentName(), Flow: b.flow}, Page: b.page}.String(),
+		DisplayName: b.pageName,
+	}
+	if len(b.formParams) > 0 {
+		infos := make([]*cx.PageInfo_FormInfo_ParameterInfo, 0, len(b.formParams))
+		for _, p := range b.formParams {
+			info := &cx.PageInfo_FormInfo_ParameterInfo{
+				DisplayName:   p.name,
+				Required:      p.required,
+				State:         p.state,
+				JustCollected: p.justCollected,
+			}
+			if p.value != nil {
+				info.Value, err = structpb.NewValue(p.value)
+				if err != nil {
+					return nil, fmt.Errorf("form parameter %s: %w", p.name, err)
+				}
+			}
+			infos = append(infos, info)
+		}
+		req.PageInfo.FormInfo = &cx.PageInfo_FormInfo{ParameterInfo: infos}
+	}
+
+	if b.intent != "" || b.intentName != "" || len(b.intentParams) > 0 {
+		info := &cx.WebhookRequest_IntentInfo{
+			DisplayName: b.intentName,
+			Confidence:  b.intentConfidence,
+			Parameters:  make(map[string]*cx.WebhookRequest_IntentInfo_IntentParameterValue),
+		}
+		if b.intent != "" {
+			info.LastMatchedIntent = ezcx.IntentName{AgentName: b.agentName(), Intent: b.intent}.String()
+		}
+		for name, p := range b.intentParams {
+			v, err := structpb.NewValue(p.resolved)
+			if err != nil {
+				return nil, fmt.Errorf("intent parameter %s: %w", name, err)
+			}
+			info.Parameters[name] = &cx.WebhookRequest_IntentInfo_IntentParameterValue{
+				OriginalValue: p.original,
+				ResolvedValue: v,
+			}
+		}
+		req.IntentInfo = info
+	}
+
+	if len(b.payload) > 0 {
+		fields, err := structpbutil.ToProtoMap(b.payload)
+		if err != nil {
+			return nil, fmt.Errorf("payload: %w", err)
+		}
+		req.Payload = &structpb.Struct{Fields: fields}
+	}
+	req.Messages = b.messages
+	req.SentimentAnalysisResult = b.sentiment
+
+	ctx := b.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req.SetContext(ctx)
+	return req, nil
+}
+
+// MustBuild is like Build but panics if the WebhookRequest can't be built.
+func (b *RequestBuilder) MustBuild() *ezcx.WebhookRequest {
+	req, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return req
+}
+
+// HTTPRequest builds the WebhookRequest and wraps it in an incoming
+// *http.Request POSTed to target, suitable for (ezcx.HandlerFunc).ServeHTTP.
+func (b *RequestBuilder) HTTPRequest(target string) (*http.Request, error) {
+	req, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = req.WriteRequest(&buf)
+	if err != nil {
+		return nil, err
+	}
+	r := httptest.NewRequest(http.MethodPost, target, &buf)
+	r.Header.Set("Content-Type", "application/json")
+	return r.WithContext(req.Context()), nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcxtest
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/googlecloudplatform/ezcx"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestNewRequestDefaults(t *testing.T) {
+	req, err := NewRequest().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := req.GetSessionInfo().GetSession()
+	prefix := "projects/ezcx-test/locations/global/agents/ezcx-test-agent/sessions/"
+	if !strings.HasPrefix(session, prefix) || len(session) == len(prefix) {
+		t.Errorf("session = %q, want %q<id>", session, prefix)
+	}
+	if req.DetectIntentResponseId == "" {
+		t.Error("detectIntentResponseId is empty")
+	}
+	want := "projects/ezcx-test/locations/global/agents/ezcx-test-agent/flows/00000000-0000-0000-0000-000000000000/pages/START_PAGE"
+	if got := req.GetPageInfo().GetCurrentPage(); got != want {
+		t.Errorf("current page = %q, want %q", got, want)
+	}
+	if req.LanguageCode != "en" {
+		t.Errorf("language = %q, want en", req.LanguageCode)
+	}
+	if req.Context() == nil {
+		t.Error("context is nil")
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	req, err := NewRequest().
+		Project("p").Location("us-central1").Agent("a").Environment("e").SessionID("s").
+		Flow("f").Page("pg", "Checkout").
+		Tag("checkout").
+		Text("pay with my card").
+		Language("de").
+		Intent("i", "pay", 0.9).
+		IntentParam("amount", "five dollars", SysUnitCurrency(5, "USD")).
+		SessionParam("dob", SysDate(1970, time.January, 2)).
+		FormParam("card", "visa").
+		FormParamState("zip", nil, Empty).
+		Required("card", "zip").
+		JustCollected("card").
+		Payload("channel", "web").
+		Message("one moment").
+		Sentiment(0.5, 1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.GetSessionInfo().GetSession(), "projects/p/locations/us-central1/agents/a/environments/e/sessions/s"; got != want {
+		t.Errorf("session = %q, want %q", got, want)
+	}
+	if got := req.GetFulfillmentInfo().GetTag(); got != "checkout" {
+		t.Errorf("tag = %q, want checkout", got)
+	}
+	if got := req.GetText(); got != "pay with my card" {
+		t.Errorf("text = %q", got)
+	}
+	if got, want := req.GetIntentInfo().GetLastMatchedIntent(), "projects/p/locations/us-central1/agents/a/intents/i"; got != want {
+		t.Errorf("intent = %q, want %q", got, want)
+	}
+	amount := req.GetIntentInfo().GetParameters()["amount"]
+	if amount.GetOriginalValue() != "five dollars" || amount.GetResolvedValue().GetStructValue().AsMap()["currency"] != "USD" {
+		t.Errorf("intent parameter amount = %v", amount)
+	}
+	dob, ok := req.GetSessionParameter("dob")
+	if !ok || !reflect.DeepEqual(dob, map[string]any{"year": 1970.0, "month": 1.0, "day": 2.0}) {
+		t.Errorf("dob = %v", dob)
+	}
+	form := req.GetPageInfo().GetFormInfo().GetParameterInfo()
+	if len(form) != 2 {
+		t.Fatalf("got %d form parameters, want 2", len(form))
+	}
+	if form[0].State != Filled || !form[0].Required || !form[0].JustCollected || form[0].GetValue().GetStringValue() != "visa" {
+		t.Errorf("card = %v", form[0])
+	}
+	if form[1].State != Empty || !form[1].Required || form[1].JustCollected || form[1].Value != nil {
+		t.Errorf("zip = %v", form[1])
+	}
+	if got := req.GetPageInfo().GetDisplayName(); got != "Checkout" {
+		t.Errorf("page display name = %q", got)
+	}
+	if v, _ := req.GetPayloadParameter("channel"); v != "web" {
+		t.Errorf("payload channel = %v", v)
+	}
+	if len(req.Messages) != 1 || req.GetSentimentAnalysisResult().GetScore() != 0.5 {
+		t.Errorf("messages = %v, sentiment = %v", req.Messages, req.GetSentimentAnalysisResult())
+	}
+}
+
+func TestBuildError(t *testing.T) {
+	_, err := NewRequest().SessionParam("bad", make(chan int)).Build()
+	if err == nil {
+		t.Fatal("expected an error for an unconvertible parameter")
+	}
+}
+
+func TestHTTPRequest(t *testing.T) {
+	r, err := NewRequest().Tag("echo").SessionParam("n", 1).HTTPRequest("/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	h := ezcx.HandlerFunc(func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		res.AddTextResponse(req.GetFulfillmentInfo().GetTag())
+		return nil
+	})
+	h.ServeHTTP(w, r)
+	var res cx.WebhookResponse
+	err = protojson.Unmarshal(w.Body.Bytes(), &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.GetFulfillmentResponse().GetMessages()[0].GetText().GetText()[0]; got != "echo" {
+		t.Errorf("response text = %q, want echo", got)
+	}
+}
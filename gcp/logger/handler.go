@@ -0,0 +1,257 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Keys of the special fields recognized by Cloud Logging.  Attributes logged
+// with these keys outside of any group are promoted to the LogEntry.
+const (
+	MessageKey        = "message"
+	SeverityKey       = "severity"
+	TimeKey           = "time"
+	SourceLocationKey = "logging.googleapis.com/sourceLocation"
+	TraceKey          = "logging.googleapis.com/trace"
+	SpanIDKey         = "logging.googleapis.com/spanId"
+	TraceSampledKey   = "logging.googleapis.com/trace_sampled"
+	LabelsKey         = "logging.googleapis.com/labels"
+	HTTPRequestKey    = "httpRequest"
+	ComponentKey      = "component"
+)
+
+// Handler is a slog.Handler that writes records as single-line JSON in
+// Cloud Logging's structured logging format.  The record's level is mapped
+// to a Severity via SeverityFromLevel.
+//
+// The HandlerOptions' ReplaceAttr is applied to attributes only; the message,
+// severity, time and sourceLocation fields are written as-is.
+type Handler struct {
+	opts   slog.HandlerOptions
+	mu     *sync.Mutex
+	out    io.Writer
+	attrs  []groupedAttrs
+	groups []string
+}
+
+// Attributes added via WithAttrs along with the groups open at the time.
+type groupedAttrs struct {
+	groups []string
+	attrs  []slog.Attr
+}
+
+var _ slog.Handler = (*Handler)(nil)
+
+// NewHandler returns a Handler that writes to w.  If opts is nil, the
+// Handler logs at LevelInfo and above and adds the sourceLocation.
+func NewHandler(w io.Writer, opts *slog.HandlerOptions) *Handler {
+	if opts == nil {
+		opts = &slog.HandlerOptions{AddSource: true}
+	}
+	return &Handler{
+		opts: *opts,
+		mu:   new(sync.Mutex),
+		out:  w,
+	}
+}
+
+func (h *Handler) Enabled(_ context.Context, l slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return l >= min
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	c := *h
+	c.attrs = append(c.attrs[:len(c.attrs):len(c.attrs)], groupedAttrs{groups: h.groups, attrs: attrs})
+	return &c
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.groups = append(c.groups[:len(c.groups):len(c.groups)], name)
+	return &c
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	entry := make(map[string]any)
+	for _, ga := range h.attrs {
+		h.addAttrs(entry, ga.groups, ga.attrs)
+	}
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	h.addAttrs(entry, h.groups, attrs)
+
+	entry[MessageKey] = r.Message
+	entry[SeverityKey] = SeverityFromLevel(r.Level).String()
+	if !r.Time.IsZero() {
+		entry[TimeKey] = r.Time.Format(time.RFC3339Nano)
+	}
+	if h.opts.AddSource && r.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := frames.Next()
+		entry[SourceLocationKey] = &SourceLocation{File: f.File, Line: int64(f.Line), Function: f.Function}
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.out.Write(b)
+	return err
+}
+
+// addAttrs adds attrs to the map nested under groups, creating the group
+// maps as needed.
+func (h *Handler) addAttrs(entry map[string]any, groups []string, attrs []slog.Attr) {
+	m := entry
+	for _, g := range groups {
+		sub, ok := m[g].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			m[g] = sub
+		}
+		m = sub
+	}
+	for _, a := range attrs {
+		h.addAttr(m, groups, a)
+	}
+}
+
+func (h *Handler) addAttr(m map[string]any, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup && h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		// Inline groups (empty keys) are added to the current map.
+		if a.Key == "" {
+			for _, ga := range attrs {
+				h.addAttr(m, groups, ga)
+			}
+			return
+		}
+		sub, ok := m[a.Key].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			m[a.Key] = sub
+		}
+		groups = append(groups[:len(groups):len(groups)], a.Key)
+		for _, ga := range attrs {
+			h.addAttr(sub, groups, ga)
+		}
+		return
+	}
+	m[a.Key] = jsonValue(a.Value)
+}
+
+func jsonValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		return v.Float64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	}
+	a := v.Any()
+	if err, ok := a.(error); ok {
+		return err.Error()
+	}
+	if _, err := json.Marshal(a); err != nil {
+		return fmt.Sprint(a)
+	}
+	return a
+}
+
+// LogEntry writes e to lg at the level corresponding to e's Severity.  The
+// entry's other fields are added as attributes under their special keys.
+// An explicit SourceLocation replaces the caller's.
+func LogEntry(ctx context.Context, lg *slog.Logger, e *CxEntry) {
+	level := e.Severity.Level()
+	if !lg.Enabled(ctx, level) {
+		return
+	}
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	// Skip runtime.Callers and LogEntry so the sourceLocation is the caller's.
+	var pcs [1]uintptr
+	if e.SourceLocation == nil {
+		runtime.Callers(2, pcs[:])
+	}
+	r := slog.NewRecord(t, level, e.Message, pcs[0])
+	if e.SourceLocation != nil {
+		r.AddAttrs(slog.Any(SourceLocationKey, e.SourceLocation))
+	}
+	if e.Component != "" {
+		r.AddAttrs(slog.String(ComponentKey, e.Component))
+	}
+	if e.Trace != "" {
+		r.AddAttrs(slog.String(TraceKey, e.Trace))
+	}
+	if e.SpanID != "" {
+		r.AddAttrs(slog.String(SpanIDKey, e.SpanID))
+	}
+	if e.TraceSampled {
+		r.AddAttrs(slog.Bool(TraceSampledKey, true))
+	}
+	if len(e.Labels) > 0 {
+		r.AddAttrs(slog.Any(LabelsKey, e.Labels))
+	}
+	if e.HTTPRequest != nil {
+		r.AddAttrs(slog.Any(HTTPRequestKey, e.HTTPRequest))
+	}
+	_ = lg.Handler().Handle(ctx, r)
+}
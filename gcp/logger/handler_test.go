@@ -0,0 +1,142 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		err := json.Unmarshal([]byte(line), &m)
+		if err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		entries = append(entries, m)
+	}
+	return entries
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	lg := slog.New(NewHandler(&buf, nil)).With(ComponentKey, "test", TraceKey, "projects/p/traces/t")
+	lg.WithGroup("order").Warn("hello", "id", 42, "err", errors.New("boom"))
+	lg.Log(context.Background(), LevelCritical, "critical")
+	lg.Debug("dropped")
+
+	entries := decodeLines(t, &buf)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2:\n%s", len(entries), buf.String())
+	}
+	e := entries[0]
+	if e["severity"] != "WARNING" || e["message"] != "hello" || e["component"] != "test" {
+		t.Errorf("unexpected entry: %v", e)
+	}
+	if e[TraceKey] != "projects/p/traces/t" {
+		t.Errorf("trace: got %v", e[TraceKey])
+	}
+	order, _ := e["order"].(map[string]any)
+	if order["id"] != float64(42) || order["err"] != "boom" {
+		t.Errorf("group: got %v", e["order"])
+	}
+	loc, _ := e[SourceLocationKey].(map[string]any)
+	if file, _ := loc["file"].(string); !strings.HasSuffix(file, "handler_test.go") {
+		t.Errorf("sourceLocation: got %v", loc)
+	}
+	if entries[1]["severity"] != "CRITICAL" {
+		t.Errorf("severity: got %v", entries[1]["severity"])
+	}
+}
+
+func TestLogEntry(t *testing.T) {
+	var buf bytes.Buffer
+	lg := slog.New(NewHandler(&buf, nil))
+	LogEntry(context.Background(), lg, CxEntryContextDone())
+	e := decodeLines(t, &buf)[0]
+	if e["severity"] != "NOTICE" || e["component"] != "ezcx.Server" {
+		t.Errorf("unexpected entry: %v", e)
+	}
+	loc, _ := e[SourceLocationKey].(map[string]any)
+	if file, _ := loc["file"].(string); !strings.HasSuffix(file, "handler_test.go") {
+		t.Errorf("sourceLocation: got %v", loc)
+	}
+
+	buf.Reset()
+	LogEntry(context.Background(), lg, &CxEntry{
+		Message:        "traced",
+		Trace:          "projects/p/traces/t",
+		SpanID:         "00f067aa0ba902b7",
+		TraceSampled:   true,
+		SourceLocation: &SourceLocation{File: "main.go", Line: 7, Function: "main.main"},
+	})
+	e = decodeLines(t, &buf)[0]
+	if e[TraceKey] != "projects/p/traces/t" || e[SpanIDKey] != "00f067aa0ba902b7" || e[TraceSampledKey] != true {
+		t.Errorf("trace fields: got %v", e)
+	}
+	loc, _ = e[SourceLocationKey].(map[string]any)
+	if loc["file"] != "main.go" || loc["line"] != "7" || loc["function"] != "main.main" {
+		t.Errorf("sourceLocation: got %v", loc)
+	}
+}
+
+func TestSeverityLevels(t *testing.T) {
+	for s := range SeverityMap {
+		if s == Default {
+			continue
+		}
+		if got := SeverityFromLevel(s.Level()); got != s {
+			t.Errorf("%s: round trip got %s", s, got)
+		}
+	}
+}
+
+func TestCxEntrySingleLine(t *testing.T) {
+	s := CxEntryServerError(errors.New("boom")).String()
+	if strings.Contains(s, "\n") {
+		t.Errorf("entry spans multiple lines: %s", s)
+	}
+	if !strings.Contains(s, `"severity":"CRITICAL"`) {
+		t.Errorf("severity isn't a string: %s", s)
+	}
+}
+
+func TestTraceFromRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1;o=1")
+	traceID, spanID, sampled := TraceFromRequest(r)
+	if traceID != "105445aa7843bc8bf206b12000100000" || spanID != "0000000000000001" || !sampled {
+		t.Errorf("X-Cloud-Trace-Context: got %s %s %v", traceID, spanID, sampled)
+	}
+
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	traceID, spanID, sampled = TraceFromRequest(r)
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spanID != "00f067aa0ba902b7" || sampled {
+		t.Errorf("traceparent: got %s %s %v", traceID, spanID, sampled)
+	}
+
+	if got := TraceName("p", traceID); got != "projects/p/traces/4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("TraceName: got %s", got)
+	}
+}
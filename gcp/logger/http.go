@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HTTPRequest is the httpRequest special field of a structured entry.
+type HTTPRequest struct {
+	RequestMethod string `json:"requestMethod,omitempty"`
+	RequestURL    string `json:"requestUrl,omitempty"`
+	RequestSize   int64  `json:"requestSize,string,omitempty"`
+	Status        int    `json:"status,omitempty"`
+	UserAgent     string `json:"userAgent,omitempty"`
+	RemoteIP      string `json:"remoteIp,omitempty"`
+	Referer       string `json:"referer,omitempty"`
+	Latency       string `json:"latency,omitempty"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+// NewHTTPRequest populates an HTTPRequest from r.  Status and Latency are
+// left for the caller since they're only known once r has been served.
+func NewHTTPRequest(r *http.Request) *HTTPRequest {
+	return &HTTPRequest{
+		RequestMethod: r.Method,
+		RequestURL:    r.URL.String(),
+		RequestSize:   r.ContentLength,
+		UserAgent:     r.UserAgent(),
+		RemoteIP:      r.RemoteAddr,
+		Referer:       r.Referer(),
+		Protocol:      r.Proto,
+	}
+}
+
+// TraceFromRequest returns the trace ID, span ID (16 hex characters) and
+// sampling decision carried by the traceparent header or, failing that, the
+// X-Cloud-Trace-Context header.  traceID is empty if neither is present.
+func TraceFromRequest(r *http.Request) (traceID, spanID string, sampled bool) {
+	traceID, spanID, sampled = parseTraceparent(r.Header.Get("traceparent"))
+	if traceID != "" {
+		return traceID, spanID, sampled
+	}
+	return parseCloudTraceContext(r.Header.Get("X-Cloud-Trace-Context"))
+}
+
+// TraceName formats traceID the way Cloud Logging expects the
+// logging.googleapis.com/trace field: projects/PROJECT_ID/traces/TRACE_ID.
+func TraceName(projectID, traceID string) string {
+	if traceID == "" {
+		return ""
+	}
+	if projectID == "" {
+		return traceID
+	}
+	return fmt.Sprintf("projects/%s/traces/%s", projectID, traceID)
+}
+
+// version-traceid-parentid-flags
+func parseTraceparent(v string) (string, string, bool) {
+	parts := strings.Split(v, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", "", false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return "", "", false
+	}
+	return parts[1], parts[2], flags&1 == 1
+}
+
+// TRACE_ID/SPAN_ID;o=OPTIONS where SPAN_ID is decimal.
+func parseCloudTraceContext(v string) (string, string, bool) {
+	v, opts, _ := strings.Cut(v, ";")
+	traceID, span, _ := strings.Cut(v, "/")
+	if len(traceID) != 32 {
+		return "", "", false
+	}
+	var spanID string
+	if n, err := strconv.ParseUint(span, 10, 64); err == nil && n != 0 {
+		spanID = fmt.Sprintf("%016x", n)
+	}
+	return traceID, spanID, opts == "o=1"
+}
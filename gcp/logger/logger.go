@@ -1,18 +1,27 @@
 package logger
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"time"
 )
 
+// New returns a *log.Logger that writes to os.Stderr.
+//
+// Deprecated: ezcx logs via log/slog; use NewHandler or NewSlog.
 func New() *log.Logger {
 	return log.New(os.Stderr, "", 0)
 }
 
+// NewSlog returns a *slog.Logger that writes Cloud Logging entries to
+// os.Stderr.
+func NewSlog() *slog.Logger {
+	return slog.New(NewHandler(os.Stderr, nil))
+}
+
 type Severity int
 
 const (
@@ -43,24 +52,103 @@ func (s Severity) String() string {
 	return SeverityMap[s]
 }
 
+// slog levels corresponding to each Severity.  LevelDebug, LevelInfo,
+// LevelWarning and LevelError are the slog package's own levels.
+const (
+	LevelDebug     = slog.LevelDebug
+	LevelInfo      = slog.LevelInfo
+	LevelNotice    = slog.Level(2)
+	LevelWarning   = slog.LevelWarn
+	LevelError     = slog.LevelError
+	LevelCritical  = slog.Level(12)
+	LevelAlert     = slog.Level(16)
+	LevelEmergency = slog.Level(20)
+)
+
+// Level returns the slog.Level for s.  Default is logged at LevelInfo.
+func (s Severity) Level() slog.Level {
+	switch {
+	case s >= Emergency:
+		return LevelEmergency
+	case s >= Alert:
+		return LevelAlert
+	case s >= Critical:
+		return LevelCritical
+	case s >= Error:
+		return LevelError
+	case s >= Warning:
+		return LevelWarning
+	case s >= Notice:
+		return LevelNotice
+	case s >= Info:
+		return LevelInfo
+	case s >= Debug:
+		return LevelDebug
+	}
+	return LevelInfo
+}
+
+// SeverityFromLevel maps l to the highest Severity whose level doesn't
+// exceed l; levels below LevelDebug map to Debug.
+func SeverityFromLevel(l slog.Level) Severity {
+	switch {
+	case l >= LevelEmergency:
+		return Emergency
+	case l >= LevelAlert:
+		return Alert
+	case l >= LevelCritical:
+		return Critical
+	case l >= LevelError:
+		return Error
+	case l >= LevelWarning:
+		return Warning
+	case l >= LevelNotice:
+		return Notice
+	case l >= LevelInfo:
+		return Info
+	}
+	return Debug
+}
+
+// Cloud Logging expects the severity as its LogSeverity name rather than
+// the numeric value.
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// SourceLocation identifies the line of code that emitted an entry.
+type SourceLocation struct {
+	File     string `json:"file,omitempty"`
+	Line     int64  `json:"line,string,omitempty"`
+	Function string `json:"function,omitempty"`
+}
+
+// CxEntry is a structured log entry using the special fields recognized by
+// Cloud Logging: https://cloud.google.com/logging/docs/structured-logging
 type CxEntry struct {
-	Message   string    `json:"message"`
-	Severity  Severity  `json:"severity,omitempty"`
-	Trace     string    `json:"logging.googleapis.com/trace,omitempty"`
-	Component string    `json:"component,omitempty"`
-	Time      time.Time `json:"time,omitempty"`
+	Message        string            `json:"message"`
+	Severity       Severity          `json:"severity,omitempty"`
+	Trace          string            `json:"logging.googleapis.com/trace,omitempty"`
+	SpanID         string            `json:"logging.googleapis.com/spanId,omitempty"`
+	TraceSampled   bool              `json:"logging.googleapis.com/trace_sampled,omitempty"`
+	SourceLocation *SourceLocation   `json:"logging.googleapis.com/sourceLocation,omitempty"`
+	Labels         map[string]string `json:"logging.googleapis.com/labels,omitempty"`
+	HTTPRequest    *HTTPRequest      `json:"httpRequest,omitempty"`
+	Component      string            `json:"component,omitempty"`
+	Time           time.Time         `json:"time,omitempty"`
 }
 
+// String returns the entry as a single line of JSON; Cloud Logging parses
+// each line written to stdout or stderr as one entry.
 func (e CxEntry) String() string {
-	var buf bytes.Buffer
-	enc := json.NewEncoder(&buf)
-	enc.SetIndent("", "    ")
-	e.Time = time.Now()
-	err := enc.Encode(e)
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b, err := json.Marshal(e)
 	if err != nil {
 		panic(err)
 	}
-	return buf.String()
+	return string(b)
 }
 
 func CxEntryListenAndServe(addr string) *CxEntry {
@@ -103,6 +191,22 @@ func CxEntrySignalIntercepted(sig os.Signal) *CxEntry {
 	}
 }
 
+func CxEntryReconfigure() *CxEntry {
+	return &CxEntry{
+		Severity:  Notice,
+		Message:   "ListenAndServe: ezcx server reconfiguring",
+		Component: "ezcx.Server",
+	}
+}
+
+func CxEntryShutdownInitiated() *CxEntry {
+	return &CxEntry{
+		Severity:  Notice,
+		Message:   "ListenAndServe: ezcx server graceful shutdown initiated",
+		Component: "ezcx.Server",
+	}
+}
+
 func CxEntryGracefulShutdown() *CxEntry {
 	return &CxEntry{
 		Severity:  Notice,
@@ -110,3 +214,43 @@ func CxEntryGracefulShutdown() *CxEntry {
 		Component: "ezcx.Server",
 	}
 }
+
+func CxEntryShutdownError(err error) *CxEntry {
+	return &CxEntry{
+		Severity:  Error,
+		Message:   fmt.Sprintf("ListenAndServe: ezcx server graceful shutdown failed: %s", err),
+		Component: "ezcx.Server",
+	}
+}
+
+func CxEntryReservedPath(pattern string) *CxEntry {
+	return &CxEntry{
+		Severity:  Critical,
+		Message:   fmt.Sprintf("HandleCx: %s uses a reserved path prefix (admin, health)", pattern),
+		Component: "ezcx.Server",
+	}
+}
+
+func CxEntryRequestError(err error) *CxEntry {
+	return &CxEntry{
+		Severity:  Error,
+		Message:   fmt.Sprintf("ServeHTTP: unable to read the WebhookRequest: %s", err),
+		Component: "ezcx.HandlerFunc",
+	}
+}
+
+func CxEntryHandlerError(err error) *CxEntry {
+	return &CxEntry{
+		Severity:  Error,
+		Message:   fmt.Sprintf("ServeHTTP: HandlerFunc returned a non-nil error: %s", err),
+		Component: "ezcx.HandlerFunc",
+	}
+}
+
+func CxEntryResponseError(err error) *CxEntry {
+	return &CxEntry{
+		Severity:  Error,
+		Message:   fmt.Sprintf("ServeHTTP: unable to write the WebhookResponse: %s", err),
+		Component: "ezcx.HandlerFunc",
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package idempotency keeps retried webhook calls from repeating a
+// handler's side effects.
+//
+// CX identifies each turn's webhook call by its detectIntentResponseId.
+// Middleware stores the handler's response under that ID and the tag, and
+// answers duplicate calls with the stored response instead of running the
+// handler again.
+package idempotency
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+)
+
+// KeyPrefix prefixes the SessionStore keys of stored responses.
+const KeyPrefix = "idempotency/"
+
+// Key returns the idempotency key of req: its detectIntentResponseId and
+// tag.  Requests without a detectIntentResponseId have no key.
+func Key(req *ezcx.WebhookRequest) string {
+	id := req.GetDetectIntentResponseId()
+	if id == "" {
+		return ""
+	}
+	return id + "/" + req.GetFulfillmentInfo().GetTag()
+}
+
+type call struct {
+	done chan struct{}
+	res  []byte
+	err  error
+}
+
+// Middleware returns an ezcx.Middleware that stores successful responses
+// in st for window, keyed by the session ID and Key.  A duplicate call
+// within the window gets the stored response without the handler running.
+// A duplicate that arrives while the first call is still running waits for
+// it and shares its outcome, including an error; failed calls aren't
+// stored, so later retries run the handler again.
+//
+// In-flight calls are tracked per Middleware, so only stored responses are
+// shared between instances using the same st.  Store failures are logged
+// via req.Logger() and don't fail the turn.
+func Middleware(st ezcx.SessionStore, window time.Duration) ezcx.Middleware {
+	var (
+		mu       sync.Mutex
+		inflight = make(map[string]*call)
+	)
+	return func(next ezcx.HandlerFunc) ezcx.HandlerFunc {
+		return func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+			key := Key(req)
+			if key == "" {
+				return next(res, req)
+			}
+			session, ctx := req.SessionID(), req.Context()
+			key = session + "\x00" + key
+
+			mu.Lock()
+			if c, ok := inflight[key]; ok {
+				mu.Unlock()
+				select {
+				case <-c.done:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				if c.err != nil {
+					return c.err
+				}
+				if c.res == nil {
+					// The response couldn't be marshaled.
+					return next(res, req)
+				}
+				return res.Restore(c.res)
+			}
+			c := &call{done: make(chan struct{})}
+			inflight[key] = c
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				delete(inflight, key)
+				mu.Unlock()
+				close(c.done)
+			}()
+
+			storeKey := KeyPrefix + Key(req)
+			b, err := st.Get(ctx, session, storeKey)
+			if err == nil {
+				c.res = b
+				return res.Restore(b)
+			}
+			if !errors.Is(err, ezcx.ErrSessionKeyNotFound) {
+				req.Logger().Error("idempotency: unable to get stored response", "error", err)
+			}
+
+			c.err = next(res, req)
+			if c.err != nil {
+				return c.err
+			}
+			c.res, err = res.Snapshot()
+			if err == nil {
+				err = st.Set(ctx, session, storeKey, c.res, window)
+			}
+			if err != nil {
+				req.Logger().Error("idempotency: unable to store response", "error", err)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idempotency
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/ezcxtest"
+	"github.com/googlecloudplatform/ezcx/sessionstore"
+)
+
+func TestMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	h := ezcx.Chain(func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		n := calls.Add(1)
+		res.AddTextResponse(fmt.Sprintf("order %d placed", n))
+		return nil
+	}, Middleware(sessionstore.NewMemory(0), time.Minute))
+
+	turn := func(id, tag string) *ezcx.WebhookResponse {
+		t.Helper()
+		req := ezcxtest.NewRequest().SessionID("s").DetectIntentResponseID(id).Tag(tag).MustBuild()
+		res := req.InitializeResponse()
+		err := h(res, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	ezcxtest.AssertText(t, turn("r1", "order"), "order 1 placed")
+	ezcxtest.AssertText(t, turn("r1", "order"), "order 1 placed")
+	ezcxtest.AssertText(t, turn("r1", "other"), "order 2 placed")
+	ezcxtest.AssertText(t, turn("r2", "order"), "order 3 placed")
+	if calls.Load() != 3 {
+		t.Errorf("handler ran %d times", calls.Load())
+	}
+}
+
+func TestMiddlewareInFlight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	h := ezcx.Chain(func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		calls.Add(1)
+		<-release
+		res.AddTextResponse("sent")
+		return nil
+	}, Middleware(sessionstore.NewMemory(0), time.Minute))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := ezcxtest.NewRequest().SessionID("s").DetectIntentResponseID("r").Tag("sms").MustBuild()
+			res := req.InitializeResponse()
+			err := h(res, req)
+			if err != nil {
+				t.Error(err)
+			}
+			ezcxtest.AssertText(t, res, "sent")
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("handler ran %d times", calls.Load())
+	}
+}
+
+func TestMiddlewareError(t *testing.T) {
+	errFailed := errors.New("failed")
+	var calls atomic.Int32
+	h := ezcx.Chain(func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		if calls.Add(1) == 1 {
+			return errFailed
+		}
+		return nil
+	}, Middleware(sessionstore.NewMemory(0), time.Minute))
+
+	for _, want := range []error{errFailed, nil} {
+		req := ezcxtest.NewRequest().DetectIntentResponseID("r").MustBuild()
+		err := h(req.InitializeResponse(), req)
+		if !errors.Is(err, want) {
+			t.Errorf("err = %v, want %v", err, want)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("a failed call was stored")
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package structpbutil converts Go values to structpb values.
+package structpbutil
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ToProtoMap converts m to a map of structpb Values, or nil if m is empty.
+// Errors name the key whose value couldn't be converted.
+func ToProtoMap(m map[string]any) (map[string]*structpb.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	pm := make(map[string]*structpb.Value, len(m))
+	for k, v := range m {
+		pv, err := structpb.NewValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		pm[k] = pv
+	}
+	return pm, nil
+}
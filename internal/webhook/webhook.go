@@ -0,0 +1,80 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webhook holds the HTTP plumbing shared by the CX and ES webhook
+// handlers.
+package webhook
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/googlecloudplatform/ezcx/gcp/logger"
+)
+
+// Request is a parsed webhook request.
+type Request[Res Response] interface {
+	SetContext(ctx context.Context)
+	InitializeResponse() Res
+}
+
+// Response is a webhook response.
+type Response interface {
+	WriteResponse(w io.Writer) error
+}
+
+// ServeHTTP parses the POSTed request with parse and calls h.  The request's
+// Context carries, under key, a child of the logger found there (or of a
+// default Cloud Logging logger) with the httpRequest field and the attrs
+// added by child.  Errors are logged; nothing is written when h fails.
+func ServeHTTP[Req Request[Res], Res Response](
+	w http.ResponseWriter,
+	r *http.Request,
+	key any,
+	parse func(*http.Request) (Req, error),
+	child func(Req, *slog.Logger) *slog.Logger,
+	h func(Res, Req) error,
+) {
+	defer r.Body.Close()
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	lg, ok := ctx.Value(key).(*slog.Logger)
+	if !ok {
+		lg = logger.NewSlog()
+	}
+	lg = lg.With(slog.Any(logger.HTTPRequestKey, logger.NewHTTPRequest(r)))
+	req, err := parse(r)
+	if err != nil {
+		logger.LogEntry(ctx, lg, logger.CxEntryRequestError(err))
+		return
+	}
+	// The request scoped logger is flowed down alongside the request's Context.
+	lg = child(req, lg)
+	req.SetContext(context.WithValue(ctx, key, lg))
+	res := req.InitializeResponse()
+	err = h(res, req)
+	if err != nil {
+		logger.LogEntry(ctx, lg, logger.CxEntryHandlerError(err))
+		return
+	}
+	err = res.WriteResponse(w)
+	if err != nil {
+		logger.LogEntry(ctx, lg, logger.CxEntryResponseError(err))
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint checks the webhook references in a Dialogflow CX agent
+// export against an ezcx route table, catching typos between the tags in
+// the CX console and the tags in code before they fail silently in
+// production.
+package lint
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/agent"
+)
+
+type Kind int
+
+const (
+	// UnhandledTag is a tag used by the agent with no handler.
+	UnhandledTag Kind = iota
+	// UnusedHandler is a handler, or a tag of a TagRouter, that the agent
+	// never calls.
+	UnusedHandler
+	// UnknownPath is a webhook whose URL path isn't routed.
+	UnknownPath
+	// UnknownWebhook is a fulfillment referring to a webhook that isn't in
+	// the export.
+	UnknownWebhook
+	// MissingTag is a fulfillment that calls a webhook without a tag.
+	MissingTag
+)
+
+var kinds = map[Kind]string{
+	UnhandledTag:   "unhandled tag",
+	UnusedHandler:  "unused handler",
+	UnknownPath:    "unknown path",
+	UnknownWebhook: "unknown webhook",
+	MissingTag:     "missing tag",
+}
+
+func (k Kind) String() string {
+	return kinds[k]
+}
+
+// Issue is a single problem found by Check.
+type Issue struct {
+	Kind    Kind
+	Webhook string
+	Path    string
+	Tag     string
+	// Locations are where in the agent the webhook and tag are used.
+	Locations []string
+}
+
+func (i Issue) String() string {
+	var sb strings.Builder
+	sb.WriteString(i.Kind.String())
+	switch i.Kind {
+	case UnhandledTag:
+		fmt.Fprintf(&sb, ": tag %q of webhook %q (%s) has no handler", i.Tag, i.Webhook, i.Path)
+	case UnusedHandler:
+		if i.Tag != "" {
+			fmt.Fprintf(&sb, ": tag %q at %s is never called by the agent", i.Tag, i.Path)
+		} else {
+			fmt.Fprintf(&sb, ": %s is never called by the agent", i.Path)
+		}
+	case UnknownPath:
+		fmt.Fprintf(&sb, ": webhook %q calls %s, which isn't routed", i.Webhook, i.Path)
+	case UnknownWebhook:
+		fmt.Fprintf(&sb, ": webhook %q isn't in the export", i.Webhook)
+	case MissingTag:
+		fmt.Fprintf(&sb, ": webhook %q is called without a tag", i.Webhook)
+	}
+	for _, loc := range i.Locations {
+		sb.WriteString("\n\t" + loc)
+	}
+	return sb.String()
+}
+
+type use struct {
+	webhook string
+	path    string
+	tag     string
+}
+
+// Check compares every webhook reference in the agent with routes, as
+// returned by (*ezcx.Server).Routes.  Routes are matched like
+// http.ServeMux patterns: exactly, or by prefix for patterns ending in
+// "/".  Tags are only checked for routes that list them i.e. TagRouters.
+// Issues are sorted by kind, then path and tag.
+func Check(a *agent.Agent, routes []ezcx.Route) []Issue {
+	uses := make(map[use][]string)
+	var issues []Issue
+	for _, ref := range a.Fulfillments() {
+		f := ref.Fulfillment
+		if f.Webhook == "" {
+			continue
+		}
+		w, ok := a.Webhooks[f.Webhook]
+		if !ok {
+			issues = append(issues, Issue{Kind: UnknownWebhook, Webhook: f.Webhook, Tag: f.Tag, Locations: []string{ref.Location}})
+			continue
+		}
+		if f.Tag == "" {
+			issues = append(issues, Issue{Kind: MissingTag, Webhook: f.Webhook, Locations: []string{ref.Location}})
+		}
+		u := use{webhook: f.Webhook, path: webhookPath(w), tag: f.Tag}
+		uses[u] = append(uses[u], ref.Location)
+	}
+
+	used := make(map[string]map[string]bool)
+	unknown := make(map[string]*Issue)
+	for u, locs := range uses {
+		r := match(routes, u.path)
+		if r == nil {
+			key := u.webhook + " " + u.path
+			if unknown[key] == nil {
+				unknown[key] = &Issue{Kind: UnknownPath, Webhook: u.webhook, Path: u.path}
+			}
+			unknown[key].Locations = append(unknown[key].Locations, locs...)
+			continue
+		}
+		if used[r.Pattern] == nil {
+			used[r.Pattern] = make(map[string]bool)
+		}
+		used[r.Pattern][u.tag] = true
+		if r.Tags != nil && u.tag != "" && !contains(r.Tags, u.tag) {
+			issues = append(issues, Issue{Kind: UnhandledTag, Webhook: u.webhook, Path: u.path, Tag: u.tag, Locations: locs})
+		}
+	}
+	for _, i := range unknown {
+		issues = append(issues, *i)
+	}
+
+	for _, r := range routes {
+		if used[r.Pattern] == nil {
+			issues = append(issues, Issue{Kind: UnusedHandler, Path: r.Pattern})
+			continue
+		}
+		for _, tag := range r.Tags {
+			if !used[r.Pattern][tag] {
+				issues = append(issues, Issue{Kind: UnusedHandler, Path: r.Pattern, Tag: tag})
+			}
+		}
+	}
+
+	for _, i := range issues {
+		sort.Strings(i.Locations)
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Tag != b.Tag {
+			return a.Tag < b.Tag
+		}
+		return a.Webhook < b.Webhook
+	})
+	return issues
+}
+
+// TagRouter returns a route table for a TagRouter serving every webhook,
+// for agents whose webhooks all point at a single handler.
+func TagRouter(tr *ezcx.TagRouter) []ezcx.Route {
+	return []ezcx.Route{{Pattern: "/", Tags: tr.Tags()}}
+}
+
+func webhookPath(w *agent.Webhook) string {
+	u, err := url.Parse(w.URI())
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// match returns the route whose pattern matches path, preferring the
+// longest pattern like http.ServeMux.
+func match(routes []ezcx.Route, path string) *ezcx.Route {
+	var best *ezcx.Route
+	for i, r := range routes {
+		ok := r.Pattern == path || (strings.HasSuffix(r.Pattern, "/") && strings.HasPrefix(path, r.Pattern))
+		if ok && (best == nil || len(r.Pattern) > len(best.Pattern)) {
+			best = &routes[i]
+		}
+	}
+	return best
+}
+
+func contains(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
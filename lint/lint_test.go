@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/agent"
+)
+
+func load(t *testing.T) *agent.Agent {
+	a, err := agent.Load("../agent/testdata/coffee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func summary(issues []Issue) []string {
+	var s []string
+	for _, i := range issues {
+		s = append(s, i.Kind.String()+" "+i.Path+" "+i.Tag)
+	}
+	return s
+}
+
+func TestCheckClean(t *testing.T) {
+	tr := ezcx.NewTagRouter()
+	for _, tag := range []string{"start-order", "confirm-order", "goodbye"} {
+		tr.Handle(tag, nil)
+	}
+	if issues := Check(load(t), TagRouter(tr)); len(issues) != 0 {
+		t.Errorf("issues = %v", issues)
+	}
+	// Handlers that accept any tag only have their paths checked.
+	if issues := Check(load(t), []ezcx.Route{{Pattern: "/order"}}); len(issues) != 0 {
+		t.Errorf("issues = %v", issues)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	routes := []ezcx.Route{
+		{Pattern: "/legacy"},
+		{Pattern: "/order", Tags: []string{"confirm-order", "good-bye", "refund", "start-order"}},
+	}
+	issues := Check(load(t), routes)
+	want := []string{
+		"unhandled tag /order goodbye",
+		"unused handler /legacy ",
+		"unused handler /order good-bye",
+		"unused handler /order refund",
+	}
+	if got := summary(issues); !reflect.DeepEqual(got, want) {
+		t.Fatalf("issues = %q, want %q", got, want)
+	}
+	msg := issues[0].String()
+	if !strings.Contains(msg, `tag "goodbye" of webhook "coffee-webhook" (/order) has no handler`) || !strings.Contains(msg, `page "Confirm"`) {
+		t.Errorf("message = %s", msg)
+	}
+}
+
+func TestCheckUnknown(t *testing.T) {
+	a := load(t)
+	page := a.Flows["Default Start Flow"].Pages["Confirm"]
+	page.EventHandlers = append(page.EventHandlers,
+		&agent.EventHandler{Event: "sys.no-match-default", TriggerFulfillment: &agent.Fulfillment{Webhook: "coffee-webhook"}},
+		&agent.EventHandler{Event: "webhook.error", TriggerFulfillment: &agent.Fulfillment{Webhook: "deleted", Tag: "oops"}},
+	)
+	issues := Check(a, []ezcx.Route{{Pattern: "/api/", Tags: []string{"x"}}})
+	want := []string{
+		"unused handler /api/ ",
+		"unknown path /order ",
+		"unknown webhook  oops",
+		"missing tag  ",
+	}
+	if got := summary(issues); !reflect.DeepEqual(got, want) {
+		t.Fatalf("issues = %q, want %q", got, want)
+	}
+	if n := len(issues[1].Locations); n != 4 {
+		t.Errorf("unknown path has %d locations, want 4", n)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	routes := []ezcx.Route{{Pattern: "/"}, {Pattern: "/api/"}, {Pattern: "/api/orders"}}
+	for path, want := range map[string]string{
+		"/api/orders":   "/api/orders",
+		"/api/orders/1": "/api/",
+		"/api":          "/",
+		"/other":        "/",
+	} {
+		if got := match(routes, path); got == nil || got.Pattern != want {
+			t.Errorf("match(%s) = %v, want %s", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+// Middleware wraps an ezcx.HandlerFunc with additional behavior.  It's the
+// ezcx analog of the func(http.Handler) http.Handler pattern, except the
+// wrapped handler deals with WebhookRequests and WebhookResponses instead
+// of raw HTTP.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// Chain wraps the handler h with the provided middleware.  The first
+// middleware provided is the outermost i.e. it's the first to see the
+// WebhookRequest and the last to see the WebhookResponse.
+func Chain(h HandlerFunc, mws ...Middleware) HandlerFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
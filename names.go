@@ -0,0 +1,178 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrResourceName is returned when a string isn't the expected kind of
+// resource name.
+var ErrResourceName = errors.New("ezcx: invalid resource name")
+
+// AgentName is the resource name of an agent,
+// projects/<project>/locations/<location>/agents/<agent>.
+type AgentName struct {
+	Project  string
+	Location string
+	Agent    string
+}
+
+// ParseAgentName parses an agent's resource name.
+func ParseAgentName(s string) (AgentName, error) {
+	ids, err := parseName(s, "projects", "locations", "agents")
+	if err != nil {
+		return AgentName{}, err
+	}
+	return AgentName{ids[0], ids[1], ids[2]}, nil
+}
+
+func (n AgentName) String() string {
+	return "projects/" + n.Project + "/locations/" + n.Location + "/agents/" + n.Agent
+}
+
+// Endpoint returns the Dialogflow API host serving the agent's location;
+// regional agents are served by <location>-dialogflow.googleapis.com.
+func (n AgentName) Endpoint() string {
+	if n.Location == "" || n.Location == "global" {
+		return "dialogflow.googleapis.com"
+	}
+	return n.Location + "-dialogflow.googleapis.com"
+}
+
+// EnvironmentName is the resource name of an agent's environment.
+type EnvironmentName struct {
+	AgentName
+	Environment string
+}
+
+// ParseEnvironmentName parses an environment's resource name.
+func ParseEnvironmentName(s string) (EnvironmentName, error) {
+	ids, err := parseName(s, "projects", "locations", "agents", "environments")
+	if err != nil {
+		return EnvironmentName{}, err
+	}
+	return EnvironmentName{AgentName{ids[0], ids[1], ids[2]}, ids[3]}, nil
+}
+
+func (n EnvironmentName) String() string {
+	return n.AgentName.String() + "/environments/" + n.Environment
+}
+
+// SessionName is the resource name of a session.  Environment is empty for
+// sessions of the draft agent.
+type SessionName struct {
+	AgentName
+	Environment string
+	Session     string
+}
+
+// ParseSessionName parses a session's resource name, with or without an
+// environment.
+func ParseSessionName(s string) (SessionName, error) {
+	ids, err := parseName(s, "projects", "locations", "agents", "sessions")
+	if err == nil {
+		return SessionName{AgentName{ids[0], ids[1], ids[2]}, "", ids[3]}, nil
+	}
+	ids, err = parseName(s, "projects", "locations", "agents", "environments", "sessions")
+	if err != nil {
+		return SessionName{}, err
+	}
+	return SessionName{AgentName{ids[0], ids[1], ids[2]}, ids[3], ids[4]}, nil
+}
+
+func (n SessionName) String() string {
+	if n.Environment == "" {
+		return n.AgentName.String() + "/sessions/" + n.Session
+	}
+	return n.AgentName.String() + "/environments/" + n.Environment + "/sessions/" + n.Session
+}
+
+// FlowName is the resource name of a flow.
+type FlowName struct {
+	AgentName
+	Flow string
+}
+
+// ParseFlowName parses a flow's resource name.
+func ParseFlowName(s string) (FlowName, error) {
+	ids, err := parseName(s, "projects", "locations", "agents", "flows")
+	if err != nil {
+		return FlowName{}, err
+	}
+	return FlowName{AgentName{ids[0], ids[1], ids[2]}, ids[3]}, nil
+}
+
+func (n FlowName) String() string {
+	return n.AgentName.String() + "/flows/" + n.Flow
+}
+
+// PageName is the resource name of a page.  Page may also be one of the
+// special transition targets e.g. END_SESSION.
+type PageName struct {
+	FlowName
+	Page string
+}
+
+// ParsePageName parses a page's resource name.
+func ParsePageName(s string) (PageName, error) {
+	ids, err := parseName(s, "projects", "locations", "agents", "flows", "pages")
+	if err != nil {
+		return PageName{}, err
+	}
+	return PageName{FlowName{AgentName{ids[0], ids[1], ids[2]}, ids[3]}, ids[4]}, nil
+}
+
+func (n PageName) String() string {
+	return n.FlowName.String() + "/pages/" + n.Page
+}
+
+// IntentName is the resource name of an intent.
+type IntentName struct {
+	AgentName
+	Intent string
+}
+
+// ParseIntentName parses an intent's resource name.
+func ParseIntentName(s string) (IntentName, error) {
+	ids, err := parseName(s, "projects", "locations", "agents", "intents")
+	if err != nil {
+		return IntentName{}, err
+	}
+	return IntentName{AgentName{ids[0], ids[1], ids[2]}, ids[3]}, nil
+}
+
+func (n IntentName) String() string {
+	return n.AgentName.String() + "/intents/" + n.Intent
+}
+
+// parseName returns the IDs in s, which must alternate between the given
+// collections and non-empty IDs.
+func parseName(s string, collections ...string) ([]string, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2*len(collections) {
+		return nil, fmt.Errorf("%w: %q", ErrResourceName, s)
+	}
+	ids := make([]string, len(collections))
+	for i, c := range collections {
+		if parts[2*i] != c || parts[2*i+1] == "" {
+			return nil, fmt.Errorf("%w: %q", ErrResourceName, s)
+		}
+		ids[i] = parts[2*i+1]
+	}
+	return ids, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+)
+
+func parser[T fmt.Stringer](parse func(string) (T, error)) func(string) (fmt.Stringer, error) {
+	return func(s string) (fmt.Stringer, error) { return parse(s) }
+}
+
+func TestParseNames(t *testing.T) {
+	agent := AgentName{"p", "us-central1", "a"}
+	for _, tc := range []struct {
+		s     string
+		parse func(string) (fmt.Stringer, error)
+		want  fmt.Stringer
+	}{
+		{"projects/p/locations/us-central1/agents/a", parser(ParseAgentName), agent},
+		{"projects/p/locations/us-central1/agents/a/environments/e", parser(ParseEnvironmentName), EnvironmentName{agent, "e"}},
+		{"projects/p/locations/us-central1/agents/a/sessions/s", parser(ParseSessionName), SessionName{agent, "", "s"}},
+		{"projects/p/locations/us-central1/agents/a/environments/e/sessions/s", parser(ParseSessionName), SessionName{agent, "e", "s"}},
+		{"projects/p/locations/us-central1/agents/a/flows/f", parser(ParseFlowName), FlowName{agent, "f"}},
+		{"projects/p/locations/us-central1/agents/a/flows/f/pages/END_SESSION", parser(ParsePageName), PageName{FlowName{agent, "f"}, "END_SESSION"}},
+		{"projects/p/locations/us-central1/agents/a/intents/i", parser(ParseIntentName), IntentName{agent, "i"}},
+	} {
+		got, err := tc.parse(tc.s)
+		if err != nil {
+			t.Errorf("parse(%q): %v", tc.s, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parse(%q) = %+v, want %+v", tc.s, got, tc.want)
+		}
+		if got.String() != tc.s {
+			t.Errorf("String() = %q, want %q", got.String(), tc.s)
+		}
+	}
+	for _, s := range []string{
+		"",
+		"projects/p/locations/l/agents/a/",
+		"projects/p/locations//agents/a",
+		"projects/p/locations/l/agents/a/flows/f",
+		"projects/p/regions/l/agents/a",
+	} {
+		_, err := ParseAgentName(s)
+		if !errors.Is(err, ErrResourceName) {
+			t.Errorf("ParseAgentName(%q) = %v", s, err)
+		}
+	}
+	if got := agent.Endpoint(); got != "us-central1-dialogflow.googleapis.com" {
+		t.Errorf("Endpoint() = %q", got)
+	}
+	if got := (AgentName{"p", "global", "a"}).Endpoint(); got != "dialogflow.googleapis.com" {
+		t.Errorf("Endpoint() = %q", got)
+	}
+}
+
+func TestRequestNames(t *testing.T) {
+	req := NewWebhookRequest()
+	req.SessionInfo = &cx.SessionInfo{Session: "projects/p/locations/europe-west1/agents/a/environments/e/sessions/s"}
+	req.PageInfo = &cx.PageInfo{CurrentPage: "projects/p/locations/europe-west1/agents/a/flows/f/pages/pg"}
+	for name, got := range map[string]string{
+		"ProjectID":     req.ProjectID(),
+		"Location":      req.Location(),
+		"AgentID":       req.AgentID(),
+		"EnvironmentID": req.EnvironmentID(),
+		"SessionID":     req.SessionID(),
+		"FlowID":        req.FlowID(),
+		"PageID":        req.PageID(),
+	} {
+		want := map[string]string{
+			"ProjectID": "p", "Location": "europe-west1", "AgentID": "a", "EnvironmentID": "e",
+			"SessionID": "s", "FlowID": "f", "PageID": "pg",
+		}[name]
+		if got != want {
+			t.Errorf("%s() = %q, want %q", name, got, want)
+		}
+	}
+
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "env-project")
+	req.SessionInfo = &cx.SessionInfo{Session: "bare-session"}
+	req.PageInfo = nil
+	if req.SessionID() != "bare-session" || req.ProjectID() != "env-project" || req.AgentID() != "" || req.PageID() != "" {
+		t.Errorf("bare session: %q %q %q %q", req.SessionID(), req.ProjectID(), req.AgentID(), req.PageID())
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// The protos are decoded with DiscardUnknown, so fields Google adds before
+// the Go protos are updated would be lost.  The request's JSON is kept for
+// RawField and Unknown.
+
+// rawFields returns the request's JSON as decoded by encoding/json.  Requests
+// built in code are marshaled first.
+func (req *WebhookRequest) rawFields() map[string]any {
+	if req.rawJSON != nil {
+		return req.rawJSON
+	}
+	b := req.raw
+	if b == nil {
+		b, _ = protojson.Marshal(&req.WebhookRequest)
+	}
+	var m map[string]any
+	if json.Unmarshal(b, &m) != nil || m == nil {
+		m = make(map[string]any)
+	}
+	req.rawJSON = m
+	return m
+}
+
+// RawField returns the value at path in the request's JSON, e.g.
+// "sessionInfo.parameters.size" or "messages.0.text.text.0", as decoded by
+// encoding/json.  It reports false if there's no such field.
+func (req *WebhookRequest) RawField(path string) (any, bool) {
+	var v any = req.rawFields()
+	for _, seg := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			var ok bool
+			v, ok = node[seg]
+			if !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// Unknown returns the fields of the request's JSON that the protos don't
+// know, keyed by their path as for RawField.  Parameters and payloads are
+// free-form, so they never contain unknown fields.
+func (req *WebhookRequest) Unknown() map[string]any {
+	unknown := make(map[string]any)
+	unknownFields(req.rawFields(), req.WebhookRequest.ProtoReflect().Descriptor(), "", unknown)
+	return unknown
+}
+
+func unknownFields(m map[string]any, md protoreflect.MessageDescriptor, prefix string, unknown map[string]any) {
+	fields := md.Fields()
+	for k, v := range m {
+		fd := fields.ByJSONName(k)
+		if fd == nil {
+			fd = fields.ByTextName(k)
+		}
+		if fd == nil {
+			unknown[prefix+k] = v
+			continue
+		}
+		path := prefix + k + "."
+		switch {
+		case fd.IsMap():
+			if vd := fd.MapValue(); vd.Kind() == protoreflect.MessageKind {
+				if vm, ok := v.(map[string]any); ok {
+					for mk, mv := range vm {
+						unknownMessage(mv, vd.Message(), path+mk+".", unknown)
+					}
+				}
+			}
+		case fd.Kind() != protoreflect.MessageKind:
+		case fd.IsList():
+			if list, ok := v.([]any); ok {
+				for i, el := range list {
+					unknownMessage(el, fd.Message(), path+strconv.Itoa(i)+".", unknown)
+				}
+			}
+		default:
+			unknownMessage(v, fd.Message(), path, unknown)
+		}
+	}
+}
+
+func unknownMessage(v any, md protoreflect.MessageDescriptor, prefix string, unknown map[string]any) {
+	// Well-known types, e.g. Struct, have their own JSON forms.
+	if md.FullName().Parent() == "google.protobuf" {
+		return
+	}
+	if m, ok := v.(map[string]any); ok {
+		unknownFields(m, md, prefix, unknown)
+	}
+}
+
+// StrictMiddleware notices schema drift: it logs a warning via
+// req.Logger() listing the paths of unknown request fields and, if count
+// is non-nil, adds the number of unknown fields to it.  The request is
+// handled as usual.
+func StrictMiddleware(count *atomic.Int64) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(res *WebhookResponse, req *WebhookRequest) error {
+			unknown := req.Unknown()
+			if len(unknown) > 0 {
+				paths := make([]string, 0, len(unknown))
+				for path := range unknown {
+					paths = append(paths, path)
+				}
+				sort.Strings(paths)
+				req.Logger().Warn("ezcx: request has unknown fields", "fields", paths)
+				if count != nil {
+					count.Add(int64(len(paths)))
+				}
+			}
+			return next(res, req)
+		}
+	}
+}
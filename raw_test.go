@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const drifted = `{
+	"detectIntentResponseId": "r1",
+	"fulfillmentInfo": {"tag": "order"},
+	"sessionInfo": {
+		"session": "projects/p/locations/global/agents/a/sessions/s",
+		"parameters": {"size": "large", "anything": {"goes": true}},
+		"newSessionField": 1
+	},
+	"messages": [{"text": {"text": ["hi"], "newTextField": "x"}}],
+	"generativeInfo": {"currentPlaybooks": ["p1"]},
+	"language_code": "en"
+}`
+
+func TestRawField(t *testing.T) {
+	req, err := WebhookRequestFromReader(strings.NewReader(drifted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]any{
+		"fulfillmentInfo.tag":               "order",
+		"sessionInfo.parameters.size":       "large",
+		"messages.0.text.text.0":            "hi",
+		"generativeInfo.currentPlaybooks.0": "p1",
+	} {
+		got, ok := req.RawField(path)
+		if !ok || got != want {
+			t.Errorf("RawField(%q) = %v, %v, want %v", path, got, ok, want)
+		}
+	}
+	for _, path := range []string{"missing", "messages.1", "messages.x", "fulfillmentInfo.tag.deeper"} {
+		if got, ok := req.RawField(path); ok {
+			t.Errorf("RawField(%q) = %v", path, got)
+		}
+	}
+
+	built := NewWebhookRequest()
+	built.LanguageCode = "fr"
+	if got, _ := built.RawField("languageCode"); got != "fr" {
+		t.Errorf("built RawField(languageCode) = %v", got)
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	req, err := WebhookRequestFromReader(strings.NewReader(drifted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"sessionInfo.newSessionField":  1.0,
+		"messages.0.text.newTextField": "x",
+		"generativeInfo":               map[string]any{"currentPlaybooks": []any{"p1"}},
+	}
+	if got := req.Unknown(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unknown() = %v, want %v", got, want)
+	}
+
+	var count atomic.Int64
+	called := false
+	h := Chain(func(res *WebhookResponse, req *WebhookRequest) error {
+		called = true
+		return nil
+	}, StrictMiddleware(&count))
+	err = h(req.InitializeResponse(), req)
+	if err != nil || !called || count.Load() != 3 {
+		t.Errorf("err = %v, called = %v, count = %d", err, called, count.Load())
+	}
+
+	clean, err := WebhookRequestFromReader(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := clean.Unknown(); len(got) != 0 {
+		t.Errorf("sample has unknown fields %v", got)
+	}
+}
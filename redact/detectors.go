@@ -0,0 +1,79 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import "regexp"
+
+// Detector finds PII within free text.  A match is only redacted if Validate
+// is nil or returns true for it.
+type Detector struct {
+	Name     string
+	Pattern  *regexp.Regexp
+	Validate func(match string) bool
+}
+
+// Built-in detectors.  CardNumber is applied before PhoneNumber in
+// DefaultDetectors so that 16 digit card numbers aren't partially matched
+// as phone numbers.
+var (
+	Email = &Detector{
+		Name:    "EMAIL",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	}
+	CardNumber = &Detector{
+		Name:     "CARD_NUMBER",
+		Pattern:  regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		Validate: Luhn,
+	}
+	SSN = &Detector{
+		Name:    "SSN",
+		Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	}
+	PhoneNumber = &Detector{
+		Name:    "PHONE_NUMBER",
+		Pattern: regexp.MustCompile(`(?:\+\d{1,3}[\s.\-]?)?(?:\(\d{3}\)|\b\d{3})[\s.\-]?\d{3}[\s.\-]?\d{4}\b`),
+	}
+)
+
+// DefaultDetectors returns the built-in detectors in the order they're
+// applied.
+func DefaultDetectors() []*Detector {
+	return []*Detector{Email, CardNumber, SSN, PhoneNumber}
+}
+
+// Luhn reports whether the digits in s (ignoring spaces and dashes) pass the
+// Luhn checksum used by payment card numbers.
+func Luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,293 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact removes PII from WebhookRequests, WebhookResponses, log
+// records and errors before they're written anywhere.
+//
+// A Policy is declarative: it lists the parameter names whose values are
+// always redacted, the Detectors applied to every other string value and the
+// Strategy used for each.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"github.com/googlecloudplatform/ezcx"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Strategy determines how a value is redacted.
+type Strategy int
+
+const (
+	// Mask replaces the value with a placeholder naming what was redacted.
+	Mask Strategy = iota
+	// Hash replaces the value with a salted SHA-256 digest so that equal
+	// values can still be correlated.
+	Hash
+	// Drop removes the parameter entirely; within free text, the match is
+	// removed.
+	Drop
+)
+
+// Policy describes what's redacted and how.  The zero Policy redacts nothing.
+type Policy struct {
+	// Parameters maps session, form, intent and payload parameter names
+	// (matched case-insensitively, at any depth) to the Strategy applied to
+	// their values.
+	Parameters map[string]Strategy
+	// Detectors are applied to every string value not covered by Parameters.
+	Detectors []*Detector
+	// Strategy is applied to Detector matches.
+	Strategy Strategy
+	// Salt is prepended to values before they're hashed.
+	Salt string
+}
+
+// DefaultPolicy masks matches of the built-in detectors.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Detectors: DefaultDetectors(),
+		Strategy:  Mask,
+	}
+}
+
+// Redact returns a redacted copy of req using the DefaultPolicy.  Provided
+// for ad-hoc use e.g. before calling WriteRequest.
+func Redact(req *ezcx.WebhookRequest) *ezcx.WebhookRequest {
+	return DefaultPolicy().Redact(req)
+}
+
+// String redacts Detector matches within s.
+func (p *Policy) String(s string) string {
+	for _, d := range p.Detectors {
+		s = d.Pattern.ReplaceAllStringFunc(s, func(m string) string {
+			if d.Validate != nil && !d.Validate(m) {
+				return m
+			}
+			return p.apply(p.Strategy, d.Name, m)
+		})
+	}
+	return s
+}
+
+func (p *Policy) apply(s Strategy, name, v string) string {
+	switch s {
+	case Hash:
+		sum := sha256.Sum256([]byte(p.Salt + v))
+		return fmt.Sprintf("[%s:%s]", name, hex.EncodeToString(sum[:6]))
+	case Drop:
+		return ""
+	}
+	return fmt.Sprintf("[REDACTED:%s]", name)
+}
+
+func (p *Policy) parameter(key string) (Strategy, bool) {
+	if len(p.Parameters) == 0 {
+		return 0, false
+	}
+	s, ok := p.Parameters[key]
+	if ok {
+		return s, true
+	}
+	for k, s := range p.Parameters {
+		if strings.EqualFold(k, key) {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// Value redacts the value v stored under key.  Maps and slices are walked
+// recursively.  The returned bool is false if the value should be dropped.
+func (p *Policy) Value(key string, v any) (any, bool) {
+	if s, ok := p.parameter(key); ok {
+		if s == Drop {
+			return nil, false
+		}
+		return p.apply(s, key, fmt.Sprint(v)), true
+	}
+	switch v := v.(type) {
+	case string:
+		return p.String(v), true
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, mv := range v {
+			if rv, ok := p.Value(k, mv); ok {
+				m[k] = rv
+			}
+		}
+		return m, true
+	case []any:
+		l := make([]any, 0, len(v))
+		for _, lv := range v {
+			if rv, ok := p.Value("", lv); ok {
+				l = append(l, rv)
+			}
+		}
+		return l, true
+	}
+	return v, true
+}
+
+// protoValue redacts a structpb.Value in place; it returns false if the value
+// should be dropped.
+func (p *Policy) protoValue(key string, v *structpb.Value) bool {
+	if v == nil {
+		return true
+	}
+	if s, ok := p.parameter(key); ok {
+		if s == Drop {
+			return false
+		}
+		v.Kind = &structpb.Value_StringValue{StringValue: p.apply(s, key, fmt.Sprint(v.AsInterface()))}
+		return true
+	}
+	switch k := v.Kind.(type) {
+	case *structpb.Value_StringValue:
+		k.StringValue = p.String(k.StringValue)
+	case *structpb.Value_StructValue:
+		p.protoFields(k.StructValue.GetFields())
+	case *structpb.Value_ListValue:
+		values := k.ListValue.GetValues()[:0]
+		for _, lv := range k.ListValue.GetValues() {
+			if p.protoValue("", lv) {
+				values = append(values, lv)
+			}
+		}
+		if k.ListValue != nil {
+			k.ListValue.Values = values
+		}
+	}
+	return true
+}
+
+func (p *Policy) protoFields(fields map[string]*structpb.Value) {
+	for k, v := range fields {
+		if !p.protoValue(k, v) {
+			delete(fields, k)
+		}
+	}
+}
+
+// Redact returns a redacted deep copy of req; req itself isn't modified.
+// Session, form, intent and payload parameters are redacted along with the
+// user's text or transcript and any messages.
+func (p *Policy) Redact(req *ezcx.WebhookRequest) *ezcx.WebhookRequest {
+	out := ezcx.NewWebhookRequest()
+	proto.Merge(&out.WebhookRequest, &req.WebhookRequest)
+
+	p.protoFields(out.GetSessionInfo().GetParameters())
+	p.protoFields(out.WebhookRequest.GetPayload().GetFields())
+	p.formParameters(out.GetPageInfo())
+	if info := out.GetIntentInfo(); info != nil {
+		for k, v := range info.Parameters {
+			s, ok := p.parameter(k)
+			switch {
+			case ok && s == Drop:
+				delete(info.Parameters, k)
+				continue
+			case ok:
+				v.OriginalValue = p.apply(s, k, v.OriginalValue)
+			default:
+				v.OriginalValue = p.String(v.OriginalValue)
+			}
+			p.protoValue(k, v.ResolvedValue)
+		}
+	}
+	switch q := out.Query.(type) {
+	case *cx.WebhookRequest_Text:
+		q.Text = p.String(q.Text)
+	case *cx.WebhookRequest_Transcript:
+		q.Transcript = p.String(q.Transcript)
+	}
+	p.messages(out.Messages)
+	return out
+}
+
+// RedactResponse returns a redacted deep copy of res; res itself isn't
+// modified.
+func (p *Policy) RedactResponse(res *ezcx.WebhookResponse) *ezcx.WebhookResponse {
+	out := ezcx.NewWebhookResponse()
+	proto.Merge(&out.WebhookResponse, &res.WebhookResponse)
+
+	p.protoFields(out.GetSessionInfo().GetParameters())
+	p.protoFields(out.WebhookResponse.GetPayload().GetFields())
+	p.formParameters(out.GetPageInfo())
+	p.messages(out.GetFulfillmentResponse().GetMessages())
+	return out
+}
+
+func (p *Policy) formParameters(info *cx.PageInfo) {
+	params := info.GetFormInfo().GetParameterInfo()
+	if params == nil {
+		return
+	}
+	kept := params[:0]
+	for _, param := range params {
+		if p.protoValue(param.DisplayName, param.Value) {
+			kept = append(kept, param)
+		}
+	}
+	info.FormInfo.ParameterInfo = kept
+}
+
+func (p *Policy) messages(msgs []*cx.ResponseMessage) {
+	for _, msg := range msgs {
+		switch m := msg.Message.(type) {
+		case *cx.ResponseMessage_Text_:
+			if m.Text == nil {
+				continue
+			}
+			for i, txt := range m.Text.Text {
+				m.Text.Text[i] = p.String(txt)
+			}
+		case *cx.ResponseMessage_OutputAudioText_:
+			switch src := m.OutputAudioText.GetSource().(type) {
+			case *cx.ResponseMessage_OutputAudioText_Text:
+				src.Text = p.String(src.Text)
+			case *cx.ResponseMessage_OutputAudioText_Ssml:
+				src.Ssml = p.String(src.Ssml)
+			}
+		case *cx.ResponseMessage_Payload:
+			p.protoFields(m.Payload.GetFields())
+		case *cx.ResponseMessage_TelephonyTransferCall_:
+			if pn, ok := m.TelephonyTransferCall.GetEndpoint().(*cx.ResponseMessage_TelephonyTransferCall_PhoneNumber); ok {
+				pn.PhoneNumber = p.String(pn.PhoneNumber)
+			}
+		}
+	}
+}
+
+// Error returns an error whose message is redacted.  The original error is
+// still available via errors.Unwrap, errors.Is and errors.As.
+func (p *Policy) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{msg: p.String(err.Error()), err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
@@ -0,0 +1,150 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/gcp/logger"
+)
+
+func TestString(t *testing.T) {
+	p := DefaultPolicy()
+	tests := []struct {
+		in, want string
+	}{
+		{"mail me at jane.doe@example.com", "mail me at [REDACTED:EMAIL]"},
+		{"card 4111 1111 1111 1111 please", "card [REDACTED:CARD_NUMBER] please"},
+		{"order 1234 5678 9012 3456", "order 1234 5678 9012 3456"},
+		{"ssn 123-45-6789", "ssn [REDACTED:SSN]"},
+		{"call (650) 253-0000 now", "call [REDACTED:PHONE_NUMBER] now"},
+		{"call +1 650-253-0000", "call [REDACTED:PHONE_NUMBER]"},
+		{"65+", "65+"},
+	}
+	for _, tc := range tests {
+		if got := p.String(tc.in); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	for _, n := range []string{"4111111111111111", "5500-0000-0000-0004", "378282246310005"} {
+		if !Luhn(n) {
+			t.Errorf("%s should pass", n)
+		}
+	}
+	for _, n := range []string{"4111111111111112", "1234", "41111111111a1111"} {
+		if Luhn(n) {
+			t.Errorf("%s should fail", n)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	session := map[string]any{
+		"dob":     "1970-01-01",
+		"address": map[string]any{"street": "1600 Amphitheatre Pkwy", "city": "Mountain View"},
+		"phone":   "650-253-0000",
+		"note":    "email jane.doe@example.com",
+		"count":   3,
+	}
+	req, err := ezcx.NewTestingWebhookRequest(session, map[string]any{"ssn": "123-45-6789"}, map[string]any{"card": "4111111111111111"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Policy{
+		Parameters: map[string]Strategy{"DOB": Hash, "street": Mask, "phone": Drop},
+		Detectors:  DefaultDetectors(),
+		Salt:       "pepper",
+	}
+	redacted := p.Redact(req)
+
+	params := redacted.GetSessionParameters()
+	if dob, _ := params["dob"].(string); !strings.HasPrefix(dob, "[dob:") {
+		t.Errorf("dob: got %v", params["dob"])
+	}
+	address := params["address"].(map[string]any)
+	if address["street"] != "[REDACTED:street]" || address["city"] != "Mountain View" {
+		t.Errorf("address: got %v", address)
+	}
+	if _, ok := params["phone"]; ok {
+		t.Errorf("phone should be dropped")
+	}
+	if params["note"] != "email [REDACTED:EMAIL]" || params["count"] != float64(3) {
+		t.Errorf("unexpected params: %v", params)
+	}
+	if got := redacted.GetPayload()["ssn"]; got != "[REDACTED:SSN]" {
+		t.Errorf("payload ssn: got %v", got)
+	}
+	if got := redacted.GetPageFormParameters()["card"]; got != "[REDACTED:CARD_NUMBER]" {
+		t.Errorf("form card: got %v", got)
+	}
+
+	// The original request is untouched.
+	if got, _ := req.GetSessionParameter("phone"); got != "650-253-0000" {
+		t.Errorf("original request was modified: %v", got)
+	}
+}
+
+func TestRedactResponse(t *testing.T) {
+	res := ezcx.NewWebhookResponse()
+	res.AddTextResponse("We'll text 650-253-0000")
+	res.AddSessionParameters(map[string]any{"email": "jane.doe@example.com"})
+	redacted := DefaultPolicy().RedactResponse(res)
+
+	txt := redacted.FulfillmentResponse.Messages[0].GetText().Text[0]
+	if txt != "We'll text [REDACTED:PHONE_NUMBER]" {
+		t.Errorf("text: got %q", txt)
+	}
+	if got := redacted.SessionInfo.Parameters["email"].GetStringValue(); got != "[REDACTED:EMAIL]" {
+		t.Errorf("email: got %q", got)
+	}
+	if got := res.SessionInfo.Parameters["email"].GetStringValue(); got != "jane.doe@example.com" {
+		t.Errorf("original response was modified: %q", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	p := DefaultPolicy()
+	p.Parameters = map[string]Strategy{"dob": Mask}
+	lg := slog.New(p.Handler(logger.NewHandler(&buf, nil)))
+	lg.With("dob", "1970-01-01").Error("lookup failed for jane.doe@example.com",
+		"error", errors.New("no account for 4111-1111-1111-1111"))
+
+	out := buf.String()
+	for _, leak := range []string{"1970-01-01", "jane.doe@example.com", "4111-1111-1111-1111"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("%s leaked: %s", leak, out)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	base := errors.New("no account for jane.doe@example.com")
+	err := DefaultPolicy().Error(base)
+	if err.Error() != "no account for [REDACTED:EMAIL]" {
+		t.Errorf("got %q", err.Error())
+	}
+	if !errors.Is(err, base) {
+		t.Errorf("redacted error should wrap the original")
+	}
+}
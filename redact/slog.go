@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ReplaceAttr redacts an attribute; it's meant to be used as (or called from)
+// slog.HandlerOptions.ReplaceAttr.
+func (p *Policy) ReplaceAttr(_ []string, a slog.Attr) slog.Attr {
+	return p.attr(a)
+}
+
+func (p *Policy) attr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if s, ok := p.parameter(a.Key); ok {
+		if s == Drop {
+			return slog.Attr{}
+		}
+		return slog.String(a.Key, p.apply(s, a.Key, a.Value.String()))
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, p.String(a.Value.String()))
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		redacted := make([]slog.Attr, 0, len(attrs))
+		for _, ga := range attrs {
+			if ra := p.attr(ga); !ra.Equal(slog.Attr{}) {
+				redacted = append(redacted, ra)
+			}
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, p.String(v.Error()))
+		case map[string]any, []any:
+			rv, _ := p.Value("", v)
+			return slog.Any(a.Key, rv)
+		}
+	}
+	return a
+}
+
+// Handler wraps h so that every record's message and attributes are redacted
+// before h sees them.  Since ezcx logs handler errors via the server's
+// slog.Handler, wrapping that handler also redacts error reports.
+func (p *Policy) Handler(h slog.Handler) slog.Handler {
+	return &handler{p: p, next: h}
+}
+
+type handler struct {
+	p    *Policy
+	next slog.Handler
+}
+
+func (h *handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, h.p.String(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if ra := h.p.attr(a); !ra.Equal(slog.Attr{}) {
+			redacted.AddAttrs(ra)
+		}
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if ra := h.p.attr(a); !ra.Equal(slog.Attr{}) {
+			redacted = append(redacted, ra)
+		}
+	}
+	return &handler{p: h.p, next: h.next.WithAttrs(redacted)}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{p: h.p, next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Diff is a single difference between the recorded and replayed responses.
+// Path is the dotted protojson path of the field; list elements are
+// addressed by index e.g. fulfillmentResponse.messages.0.text.
+type Diff struct {
+	Path string
+	Want any
+	Got  any
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("%s: want %s, got %s", d.Path, jsonString(d.Want), jsonString(d.Got))
+}
+
+func jsonString(v any) string {
+	if v == nil {
+		return "<missing>"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// compare appends the differences between want and got to diffs, skipping
+// paths matched by ignore.
+func compare(want, got any, path []string, ignore [][]string, diffs []Diff) []Diff {
+	if ignored(path, ignore) {
+		return diffs
+	}
+	wm, wok := want.(map[string]any)
+	gm, gok := got.(map[string]any)
+	if wok && gok {
+		keys := make(map[string]struct{})
+		for k := range wm {
+			keys[k] = struct{}{}
+		}
+		for k := range gm {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			diffs = compare(wm[k], gm[k], append(path[:len(path):len(path)], k), ignore, diffs)
+		}
+		return diffs
+	}
+	wl, wok := want.([]any)
+	gl, gok := got.([]any)
+	if wok && gok {
+		n := len(wl)
+		if len(gl) > n {
+			n = len(gl)
+		}
+		for i := 0; i < n; i++ {
+			var w, g any
+			if i < len(wl) {
+				w = wl[i]
+			}
+			if i < len(gl) {
+				g = gl[i]
+			}
+			diffs = compare(w, g, append(path[:len(path):len(path)], strconv.Itoa(i)), ignore, diffs)
+		}
+		return diffs
+	}
+	if !reflect.DeepEqual(want, got) {
+		diffs = append(diffs, Diff{Path: strings.Join(path, "."), Want: want, Got: got})
+	}
+	return diffs
+}
+
+func splitPatterns(patterns []string) [][]string {
+	split := make([][]string, 0, len(patterns))
+	for _, p := range patterns {
+		split = append(split, strings.Split(p, "."))
+	}
+	return split
+}
+
+func ignored(path []string, patterns [][]string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	for _, p := range patterns {
+		if match(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether path matches pattern; "*" matches a single segment
+// and "**" matches any number of segments.
+func match(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "**":
+			for i := 0; i <= len(path); i++ {
+				if match(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(path) == 0 {
+				return false
+			}
+		default:
+			if len(path) == 0 || path[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay captures production webhook traffic and replays it against
+// handlers to catch regressions.
+//
+// Captured traffic is stored in the audit package's NDJSON Record format, so
+// audit logs double as replay fixtures.
+package replay
+
+import (
+	"math/rand"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/audit"
+)
+
+// Recorder returns an ezcx.Middleware that captures a fraction rate (0 to 1)
+// of turns to sink.  If r is non-nil, it's applied before anything is
+// written; captured traffic should always be redacted.
+func Recorder(sink audit.Sink, r audit.Redactor, rate float64) ezcx.Middleware {
+	record := audit.Middleware(sink, r)
+	return func(next ezcx.HandlerFunc) ezcx.HandlerFunc {
+		recorded := record(next)
+		return func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+			if rate < 1 && rand.Float64() >= rate {
+				return next(res, req)
+			}
+			return recorded(res, req)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/audit"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// VolatileFields are ignored by the Replayer returned by NewReplayer.
+var VolatileFields = []string{
+	"**.timestamp",
+	"**.time",
+	"**.uuid",
+	"**.requestId",
+}
+
+// Replayer feeds captured Records through a handler or a running server and
+// compares each new response against the recorded one.
+type Replayer struct {
+	// Ignore lists the response fields left out of comparisons as dotted
+	// protojson paths; "*" matches a single segment and "**" matches any
+	// number of segments e.g. "sessionInfo.parameters.order-id" or
+	// "**.timestamp".
+	Ignore []string
+	// Redactor, if non-nil, is applied to each replayed response before
+	// it's compared; use the Recorder's so that redacted values match.
+	Redactor audit.Redactor
+}
+
+// NewReplayer returns a Replayer that ignores the VolatileFields along with
+// the provided fields.
+func NewReplayer(ignore ...string) *Replayer {
+	rp := new(Replayer)
+	rp.Ignore = append(rp.Ignore, VolatileFields...)
+	rp.Ignore = append(rp.Ignore, ignore...)
+	return rp
+}
+
+// Result is the outcome of replaying a single Record.
+type Result struct {
+	// Index is the Record's position in the captured file, starting at 1.
+	Index  int
+	Record *audit.Record
+	// Response is the replayed response as protojson.
+	Response json.RawMessage
+	// HandlerErr is the error returned by the handler, if any.
+	HandlerErr error
+	// Err is set if the Record couldn't be replayed at all.
+	Err   error
+	Diffs []Diff
+}
+
+// OK reports whether the Record was replayed without differences.
+func (r *Result) OK() bool {
+	return r.Err == nil && len(r.Diffs) == 0
+}
+
+func (r *Result) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "record %d (session %s, tag %q)", r.Index, r.Record.Session, r.Record.Tag)
+	if r.Err != nil {
+		fmt.Fprintf(&sb, ": %s", r.Err)
+		return sb.String()
+	}
+	if len(r.Diffs) == 0 {
+		sb.WriteString(": OK")
+		return sb.String()
+	}
+	for _, d := range r.Diffs {
+		sb.WriteString("\n\t")
+		sb.WriteString(d.String())
+	}
+	return sb.String()
+}
+
+// Handler replays the Records in r through h in-process.
+func (rp *Replayer) Handler(r io.Reader, h ezcx.HandlerFunc) ([]*Result, error) {
+	recs, err := audit.ReadRecords(r)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*Result, 0, len(recs))
+	for i, rec := range recs {
+		result := &Result{Index: i + 1, Record: rec}
+		results = append(results, result)
+
+		req, err := ezcx.WebhookRequestFromReader(bytes.NewReader(rec.Request))
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		res := req.InitializeResponse()
+		result.HandlerErr = h(res, req)
+		var buf bytes.Buffer
+		err = res.WriteResponse(&buf)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		result.Response = buf.Bytes()
+		result.Diffs, result.Err = rp.compare(rec, result.Response, result.HandlerErr)
+	}
+	return results, nil
+}
+
+// URL replays the Records in r by POSTing them to a running server at url.
+// If client is nil, http.DefaultClient is used.  Since ezcx servers don't
+// write a body when the handler fails, an empty body is treated as a handler
+// error.
+func (rp *Replayer) URL(ctx context.Context, r io.Reader, url string, client *http.Client) ([]*Result, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	recs, err := audit.ReadRecords(r)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*Result, 0, len(recs))
+	for i, rec := range recs {
+		result := &Result{Index: i + 1, Record: rec}
+		results = append(results, result)
+
+		body, err := post(ctx, client, url, rec.Request)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		if len(bytes.TrimSpace(body)) == 0 {
+			result.HandlerErr = fmt.Errorf("empty response")
+		} else {
+			result.Response = body
+		}
+		result.Diffs, result.Err = rp.compare(rec, result.Response, result.HandlerErr)
+	}
+	return results, nil
+}
+
+func post(ctx context.Context, client *http.Client, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+func (rp *Replayer) compare(rec *audit.Record, got json.RawMessage, handlerErr error) ([]Diff, error) {
+	recErr := rec.Error != ""
+	if recErr != (handlerErr != nil) {
+		d := Diff{Path: "error"}
+		if recErr {
+			d.Want = rec.Error
+		}
+		if handlerErr != nil {
+			d.Got = handlerErr.Error()
+		}
+		return []Diff{d}, nil
+	}
+	// Responses of failed turns never reach Dialogflow CX.
+	if recErr {
+		return nil, nil
+	}
+	var want, have any
+	err := json.Unmarshal(rec.Response, &want)
+	if err != nil {
+		return nil, fmt.Errorf("recorded response: %w", err)
+	}
+	if rp.Redactor != nil {
+		got, err = rp.redact(got)
+		if err != nil {
+			return nil, fmt.Errorf("replayed response: %w", err)
+		}
+	}
+	err = json.Unmarshal(got, &have)
+	if err != nil {
+		return nil, fmt.Errorf("replayed response: %w", err)
+	}
+	return compare(want, have, nil, splitPatterns(rp.Ignore), nil), nil
+}
+
+// redact applies rp.Redactor to the protojson response b.
+func (rp *Replayer) redact(b json.RawMessage) (json.RawMessage, error) {
+	res := ezcx.NewWebhookResponse()
+	err := protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, &res.WebhookResponse)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = rp.Redactor.RedactResponse(res).WriteResponse(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// TB is the part of testing.TB that Check uses, so that this package
+// doesn't import testing.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Check replays the Records in the file at path through h and reports every
+// Result that isn't OK as a test error.
+func (rp *Replayer) Check(t TB, path string, h ezcx.HandlerFunc) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer f.Close()
+	results, err := rp.Handler(f, h)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	for _, r := range results {
+		if !r.OK() {
+			t.Errorf("%v", r)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/audit"
+	"github.com/googlecloudplatform/ezcx/redact"
+)
+
+func cxGreeter(greeting string) ezcx.HandlerFunc {
+	return func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		name, ok := req.GetSessionParameter("name")
+		if !ok {
+			return fmt.Errorf("missing session parameter: name")
+		}
+		res.AddTextResponse(fmt.Sprintf("%s %s!", greeting, name))
+		return res.AddSessionParameters(map[string]any{
+			"greeted":   true,
+			"timestamp": time.Now().String(),
+		})
+	}
+}
+
+// record captures a turn per session parameter map.
+func record(t *testing.T, h ezcx.HandlerFunc, sessions ...map[string]any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	recorded := ezcx.Chain(h, Recorder(audit.NewWriterSink(&buf), redact.DefaultPolicy(), 1))
+	for _, params := range sessions {
+		req, err := ezcx.NewTestingWebhookRequest(params, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorded(req.InitializeResponse(), req)
+	}
+	return buf.Bytes()
+}
+
+func TestReplayHandler(t *testing.T) {
+	captured := record(t, cxGreeter("Hello"), map[string]any{"name": "Yvan"}, map[string]any{})
+
+	results, err := NewReplayer().Handler(bytes.NewReader(captured), cxGreeter("Hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for _, r := range results {
+		if !r.OK() {
+			t.Error(r)
+		}
+	}
+
+	results, err = NewReplayer().Handler(bytes.NewReader(captured), cxGreeter("Hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].OK() || len(results[0].Diffs) != 1 {
+		t.Fatalf("want a single diff, got %s", results[0])
+	}
+	if d := results[0].Diffs[0]; d.Path != "fulfillmentResponse.messages.0.text.text.0" || d.Want != "Hello Yvan!" || d.Got != "Hi Yvan!" {
+		t.Errorf("unexpected diff: %s", d)
+	}
+	if !results[1].OK() {
+		t.Errorf("failed turns should match: %s", results[1])
+	}
+}
+
+func TestReplayURL(t *testing.T) {
+	captured := record(t, cxGreeter("Hello"), map[string]any{"name": "Yvan"})
+	server := httptest.NewServer(cxGreeter("Hello"))
+	defer server.Close()
+
+	results, err := NewReplayer().URL(context.Background(), bytes.NewReader(captured), server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if !r.OK() {
+			t.Error(r)
+		}
+	}
+}
+
+func TestReplayRedactor(t *testing.T) {
+	h := func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		res.AddTextResponse("We emailed yvan@example.com.")
+		return nil
+	}
+	captured := record(t, h, map[string]any{})
+
+	results, err := NewReplayer().Handler(bytes.NewReader(captured), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].OK() {
+		t.Fatal("want a diff when the replayed response isn't redacted")
+	}
+
+	rp := NewReplayer()
+	rp.Redactor = redact.DefaultPolicy()
+	results, err = rp.Handler(bytes.NewReader(captured), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].OK() {
+		t.Error(results[0])
+	}
+}
+
+func TestCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "captured.ndjson")
+	err := os.WriteFile(path, record(t, cxGreeter("Hello"), map[string]any{"name": "Yvan"}), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewReplayer().Check(t, path, cxGreeter("Hello"))
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"**.timestamp", "sessionInfo.parameters.timestamp", true},
+		{"**.timestamp", "timestamp", true},
+		{"sessionInfo.*.timestamp", "sessionInfo.parameters.timestamp", true},
+		{"sessionInfo.*", "sessionInfo.parameters.timestamp", false},
+		{"sessionInfo.**", "sessionInfo.parameters.timestamp", true},
+		{"payload.id", "payload.uuid", false},
+	}
+	for _, tc := range tests {
+		if got := match(splitPatterns([]string{tc.pattern})[0], splitPatterns([]string{tc.path})[0]); got != tc.want {
+			t.Errorf("match(%q, %q): got %v", tc.pattern, tc.path, got)
+		}
+	}
+}
@@ -18,11 +18,15 @@ import (
 	"bytes"
 	"context"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"strings"
 
 	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	cxbeta "cloud.google.com/go/dialogflow/cx/apiv3beta1/cxpb"
 	"github.com/google/uuid"
+	"github.com/googlecloudplatform/ezcx/gcp/logger"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/structpb"
 )
@@ -41,6 +45,11 @@ type WebhookRequest struct {
 	// 2022-10-08: Replaced context.Context with func () context.Context.
 	ctx func() context.Context
 	req *http.Request
+	// raw is the JSON the request was read from, if any, kept for RawField,
+	// Unknown and Beta.  rawJSON and beta cache what's decoded from it.
+	raw     []byte
+	rawJSON map[string]any
+	beta    *cxbeta.WebhookRequest
 }
 
 func NewWebhookRequest() *WebhookRequest {
@@ -86,25 +95,63 @@ func (req *WebhookRequest) Request() *http.Request {
 }
 
 func (req *WebhookRequest) Context() context.Context {
+	if req.ctx == nil {
+		return context.Background()
+	}
 	return req.ctx()
 }
 
-// .
-func (req *WebhookRequest) Logger() *log.Logger {
+// SetContext replaces the WebhookRequest's context.  Unlike
+// (*http.Request).WithContext, the WebhookRequest is modified in place;
+// Middleware uses SetContext to flow values (spans, loggers) down to handlers.
+func (req *WebhookRequest) SetContext(ctx context.Context) {
+	req.ctx = func() context.Context { return ctx }
+}
+
+// Logger returns the request scoped *slog.Logger.  It's pre-populated with
+// the request's session ID, tag, page and (when the WebhookRequest came in
+// over HTTP) httpRequest and trace.
+func (req *WebhookRequest) Logger() *slog.Logger {
 	ctx := req.Context()
 	ctxLg := ctx.Value(Logger)
 	if ctxLg == nil {
 		// During testing, it's possible the user defined logger was not
 		// flowed down.  This is provided for convenience.
-		return log.Default()
+		return req.childLogger(logger.NewSlog())
 	}
-	lg, ok := ctxLg.(*log.Logger)
+	lg, ok := ctxLg.(*slog.Logger)
 	if !ok {
-		return log.Default()
+		return req.childLogger(logger.NewSlog())
 	}
 	return lg
 }
 
+// childLogger returns a child of lg with the session ID, tag, page and, when
+// the WebhookRequest came in over HTTP, the incoming trace.
+func (req *WebhookRequest) childLogger(lg *slog.Logger) *slog.Logger {
+	attrs := make([]any, 0, 6)
+	if id := req.SessionID(); id != "" {
+		attrs = append(attrs, slog.String("session_id", id))
+	}
+	if tag := req.GetFulfillmentInfo().GetTag(); tag != "" {
+		attrs = append(attrs, slog.String("tag", tag))
+	}
+	if page := req.GetPageInfo().GetDisplayName(); page != "" {
+		attrs = append(attrs, slog.String("page", page))
+	}
+	if req.req != nil {
+		traceID, spanID, sampled := logger.TraceFromRequest(req.req)
+		if traceID != "" {
+			attrs = append(attrs,
+				slog.String(logger.TraceKey, logger.TraceName(req.ProjectID(), traceID)),
+				slog.String(logger.SpanIDKey, spanID),
+				slog.Bool(logger.TraceSampledKey, sampled),
+			)
+		}
+	}
+	return lg.With(attrs...)
+}
+
 // Sets (overrides) the PageInfo.ParameterInfos to match the provided map m
 func (req *WebhookRequest) setPageFormParameters(m map[string]any) error {
 	params := make([]*cx.PageInfo_FormInfo_ParameterInfo, 0)
@@ -160,6 +207,7 @@ func WebhookRequestFromReader(rd io.Reader) (*WebhookRequest, error) {
 	if err != nil {
 		return nil, ErrUnmarshalWrapper("WebhookRequestFromReader", err)
 	}
+	req.raw = b
 	return &req, nil
 }
 
@@ -187,6 +235,7 @@ func (req *WebhookRequest) ReadReader(rd io.Reader) error {
 	if err != nil {
 		return err
 	}
+	req.raw, req.rawJSON, req.beta = b, nil, nil
 	return nil
 }
 
@@ -313,7 +362,65 @@ func (req *WebhookRequest) GetPayloadParameter(key string) (any, bool) {
 	return protoToAny(pv), ok
 }
 
+// SessionName parses the request's session resource name.
+func (req *WebhookRequest) SessionName() (SessionName, error) {
+	return ParseSessionName(req.GetSessionInfo().GetSession())
+}
+
+// SessionID returns the ID of the request's session.  If the session isn't
+// a full resource name, its last segment is used.
+func (req *WebhookRequest) SessionID() string {
+	session := req.GetSessionInfo().GetSession()
+	if n, err := ParseSessionName(session); err == nil {
+		return n.Session
+	}
+	return session[strings.LastIndex(session, "/")+1:]
+}
+
+// ProjectID returns the project of the request's session; if the session
+// isn't a full resource name, the GOOGLE_CLOUD_PROJECT env var is used.
+func (req *WebhookRequest) ProjectID() string {
+	if n, err := req.SessionName(); err == nil {
+		return n.Project
+	}
+	return os.Getenv("GOOGLE_CLOUD_PROJECT")
+}
+
+// Location returns the location of the agent e.g. global or us-central1.
+func (req *WebhookRequest) Location() string {
+	n, _ := req.SessionName()
+	return n.Location
+}
+
+// AgentID returns the ID of the agent.
+func (req *WebhookRequest) AgentID() string {
+	n, _ := req.SessionName()
+	return n.Agent
+}
+
+// EnvironmentID returns the ID of the session's environment, or "" for
+// sessions of the draft agent.
+func (req *WebhookRequest) EnvironmentID() string {
+	n, _ := req.SessionName()
+	return n.Environment
+}
+
+// FlowID returns the ID of the current page's flow.
+func (req *WebhookRequest) FlowID() string {
+	n, _ := ParsePageName(req.GetPageInfo().GetCurrentPage())
+	return n.Flow
+}
+
+// PageID returns the ID of the current page.
+func (req *WebhookRequest) PageID() string {
+	n, _ := ParsePageName(req.GetPageInfo().GetCurrentPage())
+	return n.Page
+}
+
 // Testing
+//
+// Deprecated: Use ezcxtest.NewRequest, which covers every WebhookRequest
+// field and builds a valid session name.
 func NewTestingWebhookRequest(session, payload, pageform map[string]any) (*WebhookRequest, error) {
 	return NewWebhookRequest().initTestingWebhookRequest(session, payload, pageform)
 }
@@ -19,12 +19,15 @@ import (
 	"io"
 
 	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	cxbeta "cloud.google.com/go/dialogflow/cx/apiv3beta1/cxpb"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
 type WebhookResponse struct {
 	cx.WebhookResponse
+	beta *cxbeta.WebhookResponse
 }
 
 func NewWebhookResponse() *WebhookResponse {
@@ -154,7 +157,15 @@ func (res *WebhookResponse) AddPayload(m map[string]any) error {
 
 func (res *WebhookResponse) WriteResponse(w io.Writer) error {
 	m := protojson.MarshalOptions{Indent: "\t"}
-	b, err := m.Marshal(res)
+	var msg proto.Message = &res.WebhookResponse
+	if res.beta != nil {
+		beta, err := res.mergeBeta()
+		if err != nil {
+			return err
+		}
+		msg = beta
+	}
+	b, err := m.Marshal(msg)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,72 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrUnhandledTag = errors.New("ezcx: no handler for tag")
+
+// TagRouter dispatches WebhookRequests to handlers by fulfillment tag, so
+// a single webhook URL can serve every tag of an agent.
+type TagRouter struct {
+	handlers map[string]HandlerFunc
+	// NotFound is called for tags without a handler.  If nil, an error
+	// wrapping ErrUnhandledTag is returned.
+	NotFound HandlerFunc
+}
+
+func NewTagRouter() *TagRouter {
+	return &TagRouter{handlers: make(map[string]HandlerFunc)}
+}
+
+// Handle registers the handler for tag.
+func (tr *TagRouter) Handle(tag string, h HandlerFunc) {
+	tr.handlers[tag] = h
+}
+
+// HandleCx is a HandlerFunc that dispatches on the request's tag.
+func (tr *TagRouter) HandleCx(res *WebhookResponse, req *WebhookRequest) error {
+	tag := req.GetFulfillmentInfo().GetTag()
+	h, ok := tr.handlers[tag]
+	if ok {
+		return h(res, req)
+	}
+	if tr.NotFound != nil {
+		return tr.NotFound(res, req)
+	}
+	return fmt.Errorf("%w %q", ErrUnhandledTag, tag)
+}
+
+// Tags returns the registered tags, sorted.
+func (tr *TagRouter) Tags() []string {
+	tags := make([]string, 0, len(tr.handlers))
+	for tag := range tr.handlers {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Route is an entry in a Server's route table.  Tags lists the tags
+// handled at Pattern when it was registered with HandleTags; it's nil for
+// handlers registered with HandleCx, which may handle any tag.
+type Route struct {
+	Pattern string
+	Tags    []string
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+)
+
+func TestTagRouter(t *testing.T) {
+	tr := NewTagRouter()
+	tr.Handle("b", func(res *WebhookResponse, req *WebhookRequest) error {
+		res.AddTextResponse("b")
+		return nil
+	})
+	tr.Handle("a", CxHandler)
+	if got := tr.Tags(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("tags = %v", got)
+	}
+
+	req := NewWebhookRequest()
+	req.FulfillmentInfo = &cx.WebhookRequest_FulfillmentInfo{Tag: "b"}
+	res := NewWebhookResponse()
+	err := tr.HandleCx(res, req)
+	if err != nil || res.GetFulfillmentResponse().GetMessages()[0].GetText().GetText()[0] != "b" {
+		t.Errorf("HandleCx(b) = %v, %v", res, err)
+	}
+
+	req.FulfillmentInfo.Tag = "c"
+	err = tr.HandleCx(NewWebhookResponse(), req)
+	if !errors.Is(err, ErrUnhandledTag) {
+		t.Errorf("err = %v, want ErrUnhandledTag", err)
+	}
+}
+
+func TestServerRoutes(t *testing.T) {
+	s := NewServer(context.Background(), ":0", nil)
+	tr := NewTagRouter()
+	tr.Handle("welcome", CxHandler)
+	s.HandleTags("/cx", tr)
+	s.HandleCx("/legacy", CxHandler)
+	want := []Route{{Pattern: "/cx", Tags: []string{"welcome"}}, {Pattern: "/legacy"}}
+	if got := s.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("routes = %+v, want %+v", got, want)
+	}
+}
@@ -16,14 +16,18 @@ package ezcx
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/googlecloudplatform/ezcx/gcp/logger"
+	"github.com/googlecloudplatform/ezcx/internal/webhook"
 )
 
 var (
@@ -43,30 +47,7 @@ type HandlerFunc func(*WebhookResponse, *WebhookRequest) error
 // is missing, it should be up to the developer to handle that i.e.: return an HTTP error (400, 500)
 // or return a ResponseMessage indicating something went wrong...
 func (h HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close()
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
-	}
-	req, err := WebhookRequestFromRequest(r)
-	if err != nil {
-		log.Println("Error during WebhookRequestFromRequest")
-		log.Println(err)
-		return
-	}
-	req.ctx = r.Context // flowing down the requests's Context added..
-	res := req.InitializeResponse()
-	err = h(res, req)
-	if err != nil {
-		log.Println("Error during HandlerFunc execution")
-		log.Println(err)
-		return
-	}
-	err = res.WriteResponse(w)
-	if err != nil {
-		log.Println("Error during WebhookResponse.WriteResponse")
-		return
-	}
+	webhook.ServeHTTP(w, r, Logger, WebhookRequestFromRequest, (*WebhookRequest).childLogger, h)
 }
 
 func DefaultHealthCheck(w http.ResponseWriter, r *http.Request) {
@@ -79,16 +60,20 @@ type Server struct {
 	errs    chan error
 	server  *http.Server
 	mux     *http.ServeMux
-	lg      *log.Logger
+	lg      *slog.Logger
 	hc      http.HandlerFunc
+	mws     []Middleware
+	routes  map[string]*TagRouter
 }
 
-func NewServer(ctx context.Context, addr string, lg *log.Logger, signals ...os.Signal) *Server {
-	ctx = context.WithValue(ctx, Logger, lg)
-	return new(Server).Init(ctx, addr, lg, signals...)
+// NewServer returns an ezcx Server listening on addr that logs via the
+// slog.Handler h.  If h is nil, Cloud Logging entries are written to
+// os.Stderr (see logger.NewHandler).
+func NewServer(ctx context.Context, addr string, h slog.Handler, signals ...os.Signal) *Server {
+	return new(Server).Init(ctx, addr, h, signals...)
 }
 
-func (s *Server) Init(ctx context.Context, addr string, lg *log.Logger, signals ...os.Signal) *Server {
+func (s *Server) Init(ctx context.Context, addr string, h slog.Handler, signals ...os.Signal) *Server {
 	if len(signals) == 0 {
 		s.signals = ServerDefaultSignals
 	} else {
@@ -99,12 +84,14 @@ func (s *Server) Init(ctx context.Context, addr string, lg *log.Logger, signals
 	s.signal = make(chan os.Signal, 1)
 	signal.Notify(s.signal, s.signals...)
 
-	if lg == nil {
-		lg = log.Default()
+	if h == nil {
+		h = logger.NewHandler(os.Stderr, nil)
 	}
-	s.lg = lg
+	s.lg = slog.New(h)
+	ctx = context.WithValue(ctx, Logger, s.lg)
 
 	s.errs = make(chan error)
+	s.routes = make(map[string]*TagRouter)
 	s.mux = http.NewServeMux()
 	s.hc = DefaultHealthCheck
 	s.mux.HandleFunc("/health", s.hc)
@@ -137,6 +124,13 @@ func (s *Server) isMux(h http.Handler) bool {
 	return ok
 }
 
+// Use appends middleware to the Server's middleware chain.  The chain is
+// applied to handlers when they're registered, so Use must be called before
+// HandleCx for the middleware to take effect.
+func (s *Server) Use(mws ...Middleware) {
+	s.mws = append(s.mws, mws...)
+}
+
 var (
 	reservedPaths = map[string]struct{}{
 		"admin":  {},
@@ -153,11 +147,34 @@ func (s *Server) HandleCx(pattern string, handler HandlerFunc) {
 		pathPrefix := pathParts[1]
 		_, ok := reservedPaths[pathPrefix]
 		if ok {
-			s.lg.Fatal("admin, health are reserved path prefixes")
+			logger.LogEntry(context.Background(), s.lg, logger.CxEntryReservedPath(pattern))
+			os.Exit(1)
 		}
 	}
 
-	s.mux.Handle(pattern, handler)
+	s.mux.Handle(pattern, Chain(handler, s.mws...))
+	s.routes[pattern] = nil
+}
+
+// HandleTags registers a TagRouter for the given pattern.  Unlike HandleCx,
+// the router's tags are recorded in the Server's route table.
+func (s *Server) HandleTags(pattern string, tr *TagRouter) {
+	s.HandleCx(pattern, tr.HandleCx)
+	s.routes[pattern] = tr
+}
+
+// Routes returns the Server's route table, sorted by pattern.
+func (s *Server) Routes() []Route {
+	routes := make([]Route, 0, len(s.routes))
+	for pattern, tr := range s.routes {
+		r := Route{Pattern: pattern}
+		if tr != nil {
+			r.Tags = tr.Tags()
+		}
+		routes = append(routes, r)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Pattern < routes[j].Pattern })
+	return routes
 }
 
 // ListenAndServe listens on the TCP network address srv.Addr and then calls Serve
@@ -169,11 +186,10 @@ func (s *Server) ListenAndServe(ctx context.Context) {
 		close(s.signal)
 	}()
 	// Run ListenAndServe on a separate goroutine.
-	s.lg.Printf("EZCX server listening and serving on %s\n", s.server.Addr)
+	logger.LogEntry(ctx, s.lg, logger.CxEntryListenAndServe(s.server.Addr))
 	go func() {
 		err := s.server.ListenAndServe()
 		if err != nil && err != http.ErrServerClosed {
-			s.lg.Println(err)
 			s.errs <- err
 			close(s.errs)
 		}
@@ -183,36 +199,34 @@ func (s *Server) ListenAndServe(ctx context.Context) {
 		select {
 		// If the context is done, we need to return.
 		case <-ctx.Done():
-			s.lg.Println("EZCX server context is done")
+			logger.LogEntry(ctx, s.lg, logger.CxEntryContextDone())
 			err := ctx.Err()
 			if err != nil {
-				s.lg.Print("EZCX server context error...")
-				s.lg.Println(err)
+				logger.LogEntry(ctx, s.lg, logger.CxEntryContextError(err))
 			}
 			return
 		// If there's a non-nil error, we need to return
 		case err := <-s.errs:
 			if err != nil {
-				s.lg.Print("EZCX server non-nil error...")
-				s.lg.Println(err)
+				logger.LogEntry(ctx, s.lg, logger.CxEntryServerError(err))
 				return
 			}
 		case sig := <-s.signal:
-			s.lg.Printf("EZCX server signal %s received...", sig)
+			logger.LogEntry(ctx, s.lg, logger.CxEntrySignalIntercepted(sig))
 			switch sig {
 			case syscall.SIGHUP:
-				s.lg.Println("EZCX reconfigure", sig)
+				logger.LogEntry(ctx, s.lg, logger.CxEntryReconfigure())
 				err := s.Reconfigure()
 				if err != nil {
 					s.errs <- err
 				}
 			default:
-				s.lg.Printf("EZCX graceful shutdown initiated...")
+				logger.LogEntry(ctx, s.lg, logger.CxEntryShutdownInitiated())
 				err := s.Shutdown(ctx)
 				if err != nil {
-					s.lg.Println(err)
+					logger.LogEntry(ctx, s.lg, logger.CxEntryShutdownError(err))
 				} else {
-					s.lg.Println("EZCX shutdown SUCCESS")
+					logger.LogEntry(ctx, s.lg, logger.CxEntryGracefulShutdown())
 				}
 				return
 			}
@@ -226,11 +240,10 @@ func (s *Server) ListenAndServeTLS(ctx context.Context, certFile, keyFile string
 		close(s.signal)
 	}()
 	// Run ListenAndServe on a separate goroutine.
-	s.lg.Printf("EZCX server listening and serving on %s\n", s.server.Addr)
+	logger.LogEntry(ctx, s.lg, logger.CxEntryListenAndServe(s.server.Addr))
 	go func() {
 		err := s.server.ListenAndServeTLS(certFile, keyFile)
 		if err != nil && err != http.ErrServerClosed {
-			s.lg.Println(err)
 			s.errs <- err
 			close(s.errs)
 		}
@@ -240,36 +253,34 @@ func (s *Server) ListenAndServeTLS(ctx context.Context, certFile, keyFile string
 		select {
 		// If the context is done, we need to return.
 		case <-ctx.Done():
-			s.lg.Println("EZCX server context is done")
+			logger.LogEntry(ctx, s.lg, logger.CxEntryContextDone())
 			err := ctx.Err()
 			if err != nil {
-				s.lg.Print("EZCX server context error...")
-				s.lg.Println(err)
+				logger.LogEntry(ctx, s.lg, logger.CxEntryContextError(err))
 			}
 			return
 		// If there's a non-nil error, we need to return
 		case err := <-s.errs:
 			if err != nil {
-				s.lg.Print("EZCX server non-nil error...")
-				s.lg.Println(err)
+				logger.LogEntry(ctx, s.lg, logger.CxEntryServerError(err))
 				return
 			}
 		case sig := <-s.signal:
-			s.lg.Printf("EZCX server signal %s received...", sig)
+			logger.LogEntry(ctx, s.lg, logger.CxEntrySignalIntercepted(sig))
 			switch sig {
 			case syscall.SIGHUP:
-				s.lg.Println("EZCX reconfigure", sig)
+				logger.LogEntry(ctx, s.lg, logger.CxEntryReconfigure())
 				err := s.Reconfigure()
 				if err != nil {
 					s.errs <- err
 				}
 			default:
-				s.lg.Printf("EZCX graceful shutdown initiated...")
+				logger.LogEntry(ctx, s.lg, logger.CxEntryShutdownInitiated())
 				err := s.Shutdown(ctx)
 				if err != nil {
-					s.lg.Println(err)
+					logger.LogEntry(ctx, s.lg, logger.CxEntryShutdownError(err))
 				} else {
-					s.lg.Println("EZCX shutdown SUCCESS")
+					logger.LogEntry(ctx, s.lg, logger.CxEntryGracefulShutdown())
 				}
 				return
 			}
@@ -15,12 +15,18 @@
 package ezcx
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/googlecloudplatform/ezcx/gcp/logger"
 )
 
 func TestCxHandler(t *testing.T) {
@@ -64,3 +70,34 @@ func CxHandler(res *WebhookResponse, req *WebhookRequest) error {
 	res.SetSessionParameters(params)
 	return nil
 }
+
+func TestCxHandlerRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.WithValue(context.Background(), Logger, slog.New(logger.NewHandler(&buf, nil)))
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(sample)).WithContext(ctx)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler := HandlerFunc(func(res *WebhookResponse, req *WebhookRequest) error {
+		req.Logger().Info("handled")
+		return nil
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entry["logging.googleapis.com/trace"]; got != "projects/oktony-cx/traces/4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace: got %v", got)
+	}
+	if entry["tag"] != "nb-cohorts" || entry["session_id"] != "0591c1-9e2-06b-79c-49e9affb8" || entry["page"] != "get-national-benchmarks" {
+		t.Errorf("request attributes: got %v", entry)
+	}
+	if entry["severity"] != "INFO" || entry["message"] != "handled" {
+		t.Errorf("entry: got %v", entry)
+	}
+	httpRequest, _ := entry["httpRequest"].(map[string]any)
+	if httpRequest["requestMethod"] != http.MethodPost {
+		t.Errorf("httpRequest: got %v", httpRequest)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrSessionKeyNotFound is returned by SessionStore.Get for missing or
+	// expired keys.
+	ErrSessionKeyNotFound = errors.New("ezcx: session key not found")
+	// ErrNoSessionStore is returned by Session methods when the request's
+	// Context doesn't carry a SessionStore.
+	ErrNoSessionStore = errors.New("ezcx: no session store")
+)
+
+// SessionStore stores server-side state keyed by session ID and key.  Unlike
+// session parameters, the values are never sent to the agent.  See the
+// sessionstore package for implementations and a conformance test suite.
+type SessionStore interface {
+	// Get returns the value of key, or ErrSessionKeyNotFound.
+	Get(ctx context.Context, session, key string) ([]byte, error)
+	// Set sets the value of key.  A ttl of 0 means the value doesn't
+	// expire.
+	Set(ctx context.Context, session, key string, value []byte, ttl time.Duration) error
+	// Delete deletes key.  Deleting a missing key isn't an error.
+	Delete(ctx context.Context, session, key string) error
+	// CompareAndSwap sets key to new if its value is old, where a nil old
+	// means key must not exist.  It reports whether the swap happened.
+	CompareAndSwap(ctx context.Context, session, key string, old, new []byte, ttl time.Duration) (bool, error)
+}
+
+type sessionStore struct {
+	st  SessionStore
+	ttl time.Duration
+}
+
+// ContextWithSessionStore returns a copy of ctx carrying st; values set
+// through a request's Session expire after ttl.
+func ContextWithSessionStore(ctx context.Context, st SessionStore, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, sessionStoreKey, sessionStore{st, ttl})
+}
+
+// SessionStoreMiddleware makes st available to handlers through
+// req.Session(); values expire after ttl.
+func SessionStoreMiddleware(st SessionStore, ttl time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(res *WebhookResponse, req *WebhookRequest) error {
+			req.SetContext(ContextWithSessionStore(req.Context(), st, ttl))
+			return next(res, req)
+		}
+	}
+}
+
+// Session is the request's session's view of a SessionStore.  Values are
+// stored as JSON.
+type Session struct {
+	ID  string
+	ctx context.Context
+	sessionStore
+}
+
+// Session returns the request's session state, keyed by SessionID.
+func (req *WebhookRequest) Session() *Session {
+	ctx := req.Context()
+	st, _ := ctx.Value(sessionStoreKey).(sessionStore)
+	return &Session{req.SessionID(), ctx, st}
+}
+
+// Get decodes the value of key into v.  It reports false if key isn't set.
+func (s *Session) Get(key string, v any) (bool, error) {
+	if s.st == nil {
+		return false, ErrNoSessionStore
+	}
+	b, err := s.st.Get(s.ctx, s.ID, key)
+	if errors.Is(err, ErrSessionKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(b, v)
+}
+
+// Set sets the value of key to v.
+func (s *Session) Set(key string, v any) error {
+	if s.st == nil {
+		return ErrNoSessionStore
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.st.Set(s.ctx, s.ID, key, b, s.ttl)
+}
+
+// Delete deletes key.
+func (s *Session) Delete(key string) error {
+	if s.st == nil {
+		return ErrNoSessionStore
+	}
+	return s.st.Delete(s.ctx, s.ID, key)
+}
+
+// Update sets key to the result of f, retrying when another request
+// changes key concurrently.  f is passed a pointer to the zero value of T
+// if key isn't set.  Because of the retries f may run more than once, so it
+// must be free of side effects such as adding to the response; act on the
+// updated value after Update returns instead.
+func Update[T any](s *Session, key string, f func(*T) error) error {
+	if s.st == nil {
+		return ErrNoSessionStore
+	}
+	for {
+		var v T
+		old, err := s.st.Get(s.ctx, s.ID, key)
+		switch {
+		case errors.Is(err, ErrSessionKeyNotFound):
+			old = nil
+		case err != nil:
+			return err
+		default:
+			err = json.Unmarshal(old, &v)
+			if err != nil {
+				return err
+			}
+		}
+		err = f(&v)
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if old != nil && bytes.Equal(old, b) {
+			return nil
+		}
+		ok, err := s.st.CompareAndSwap(s.ctx, s.ID, key, old, b, s.ttl)
+		if err != nil || ok {
+			return err
+		}
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
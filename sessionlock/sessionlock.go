@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sessionlock serializes webhook calls for the same session.
+//
+// CX may call the webhook several times for one session in quick
+// succession, e.g. retries or parallel fulfillments, and handlers sharing
+// session state then race.  Middleware runs one handler per session at a
+// time.
+package sessionlock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+)
+
+// Locker locks keys.  Keyed is an in-process Locker; a distributed
+// implementation lets several instances serialize the same session.
+type Locker interface {
+	// Lock blocks until key is locked or ctx is done, in which case it
+	// returns ctx.Err().  Calling unlock releases the lock.
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+}
+
+// Keyed is a Locker holding a mutex per key.  A key's mutex is discarded
+// once nothing holds or waits for it.
+type Keyed struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sem  chan struct{}
+	refs int // holders and waiters
+}
+
+// NewKeyed returns an empty Keyed.
+func NewKeyed() *Keyed {
+	return &Keyed{locks: make(map[string]*keyedLock)}
+}
+
+// Len returns the number of keys held or waited for.
+func (k *Keyed) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.locks)
+}
+
+func (k *Keyed) Lock(ctx context.Context, key string) (func(), error) {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyedLock{sem: make(chan struct{}, 1)}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		k.release(key, l)
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.sem
+			k.release(key, l)
+		})
+	}, nil
+}
+
+func (k *Keyed) release(key string, l *keyedLock) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(k.locks, key)
+	}
+}
+
+// Middleware returns an ezcx.Middleware that runs one handler at a time per
+// session ID, locked with l.  A request waits at most wait for the lock,
+// keeping it inside the webhook timeout; after that fallback handles it.  If
+// fallback is nil, the handler runs without the lock.  Either way the
+// timeout is logged via req.Logger().  If wait isn't positive, requests wait
+// until their Context is done.  Requests without a session aren't
+// serialized.
+func Middleware(l Locker, wait time.Duration, fallback ezcx.HandlerFunc) ezcx.Middleware {
+	return func(next ezcx.HandlerFunc) ezcx.HandlerFunc {
+		onTimeout := fallback
+		if onTimeout == nil {
+			onTimeout = next
+		}
+		return func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+			id := req.SessionID()
+			if id == "" {
+				return next(res, req)
+			}
+			ctx := req.Context()
+			if wait > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, wait)
+				defer cancel()
+			}
+			start := time.Now()
+			unlock, err := l.Lock(ctx, id)
+			if err != nil {
+				req.Logger().Warn("sessionlock: unable to lock session", "error", err, "waited", time.Since(start))
+				return onTimeout(res, req)
+			}
+			defer unlock()
+			return next(res, req)
+		}
+	}
+}
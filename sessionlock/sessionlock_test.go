@@ -0,0 +1,157 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessionlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/ezcxtest"
+)
+
+func TestKeyed(t *testing.T) {
+	k := NewKeyed()
+	ctx := context.Background()
+	unlock, err := k.Lock(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Other keys aren't blocked.
+	unlockB, err := k.Lock(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlockB()
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = k.Lock(short, "a")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Lock while held = %v", err)
+	}
+	unlock()
+	unlock() // unlocking twice is harmless
+	if k.Len() != 0 {
+		t.Errorf("Len() = %d after unlocking everything", k.Len())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	h := func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}
+	k := NewKeyed()
+	serialized := ezcx.Chain(h, Middleware(k, time.Second, nil))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := ezcxtest.NewRequest().SessionID("same").MustBuild()
+			err := serialized(req.InitializeResponse(), req)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxRunning.Load() != 1 {
+		t.Errorf("%d handlers ran at once", maxRunning.Load())
+	}
+	if k.Len() != 0 {
+		t.Errorf("Len() = %d", k.Len())
+	}
+}
+
+func TestMiddlewareTimeout(t *testing.T) {
+	k := NewKeyed()
+	unlock, _ := k.Lock(context.Background(), "busy")
+	defer unlock()
+
+	h := func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		res.AddTextResponse("handled")
+		return nil
+	}
+	fallback := func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		res.AddTextResponse("busy")
+		return nil
+	}
+	for _, tc := range []struct {
+		fallback ezcx.HandlerFunc
+		want     string
+	}{
+		{fallback, "busy"},
+		{nil, "handled"},
+	} {
+		req := ezcxtest.NewRequest().SessionID("busy").MustBuild()
+		res := req.InitializeResponse()
+		err := ezcx.Chain(h, Middleware(k, 10*time.Millisecond, tc.fallback))(res, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ezcxtest.AssertText(t, res, tc.want)
+	}
+}
+
+func TestMiddlewareNoWait(t *testing.T) {
+	k := NewKeyed()
+	h := func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		res.AddTextResponse("handled")
+		return nil
+	}
+	fallback := func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		res.AddTextResponse("busy")
+		return nil
+	}
+	locked := ezcx.Chain(h, Middleware(k, 0, fallback))
+	for i := 0; i < 20; i++ {
+		req := ezcxtest.NewRequest().SessionID("free").MustBuild()
+		res := req.InitializeResponse()
+		err := locked(res, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ezcxtest.AssertText(t, res, "handled")
+	}
+
+	unlock, _ := k.Lock(context.Background(), "busy")
+	defer unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req := ezcxtest.NewRequest().SessionID("busy").MustBuild()
+	req.SetContext(ctx)
+	res := req.InitializeResponse()
+	err := locked(res, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ezcxtest.AssertText(t, res, "busy")
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessionstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+)
+
+var _ ezcx.SessionStore = (*File)(nil)
+
+// File is a SessionStore that keeps each key in its own file under a
+// directory, so state survives restarts.  It's safe for concurrent use
+// within a process but not across processes sharing the directory.
+type File struct {
+	mu  sync.Mutex
+	dir string
+	now func() time.Time
+}
+
+type fileEntry struct {
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// NewFile returns a File store keeping its files under dir, which is
+// created if needed.
+func NewFile(dir string) (*File, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &File{dir: dir, now: time.Now}, nil
+}
+
+// path returns the file for key.  Session IDs and keys are hashed so any
+// string can be used.
+func (f *File) path(session, key string) string {
+	s := sha256.Sum256([]byte(session))
+	k := sha256.Sum256([]byte(key))
+	return filepath.Join(f.dir, hex.EncodeToString(s[:]), hex.EncodeToString(k[:]))
+}
+
+func (f *File) read(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ezcx.ErrSessionKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e fileEntry
+	err = json.Unmarshal(b, &e)
+	if err != nil {
+		return nil, err
+	}
+	if !e.Expires.IsZero() && !f.now().Before(e.Expires) {
+		os.Remove(path)
+		return nil, ezcx.ErrSessionKeyNotFound
+	}
+	if e.Value == nil {
+		e.Value = []byte{}
+	}
+	return e.Value, nil
+}
+
+// write replaces the file at path atomically.
+func (f *File) write(path string, value []byte, ttl time.Duration) error {
+	e := fileEntry{Value: value}
+	if ttl > 0 {
+		e.Expires = f.now().Add(ttl)
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *File) Get(ctx context.Context, session, key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.read(f.path(session, key))
+}
+
+func (f *File) Set(ctx context.Context, session, key string, value []byte, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.write(f.path(session, key), value, ttl)
+}
+
+func (f *File) Delete(ctx context.Context, session, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := os.Remove(f.path(session, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (f *File) CompareAndSwap(ctx context.Context, session, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := f.path(session, key)
+	cur, err := f.read(path)
+	if errors.Is(err, ezcx.ErrSessionKeyNotFound) {
+		cur = nil
+	} else if err != nil {
+		return false, err
+	}
+	if (cur == nil) != (old == nil) || (cur != nil && !bytes.Equal(cur, old)) {
+		return false, nil
+	}
+	return true, f.write(path, new, ttl)
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sessionstore provides ezcx.SessionStore implementations.
+package sessionstore
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+)
+
+var _ ezcx.SessionStore = (*Memory)(nil)
+
+type entryKey struct {
+	session, key string
+}
+
+type entry struct {
+	k       entryKey
+	value   []byte
+	expires time.Time
+}
+
+// Memory is an in-memory SessionStore.  It holds at most size keys,
+// evicting the least recently used key when full.  Expired keys are removed
+// as they're found and by a sweep that runs once the store has taken as
+// many new keys as it held after the last sweep.
+type Memory struct {
+	mu      sync.Mutex
+	size    int
+	entries map[entryKey]*list.Element
+	lru     *list.List // front is most recently used
+	now     func() time.Time
+	pending int // new keys until the next sweep
+}
+
+// NewMemory returns a Memory store holding at most size keys.  If size is
+// 0, the store is unbounded.
+func NewMemory(size int) *Memory {
+	return &Memory{
+		size:    size,
+		entries: make(map[entryKey]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// Len returns the number of keys in the store, including expired keys that
+// haven't been removed yet.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+// lookup returns the live entry for k, marking it as recently used.
+func (m *Memory) lookup(k entryKey) *entry {
+	el, ok := m.entries[k]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*entry)
+	if !e.expires.IsZero() && !m.now().Before(e.expires) {
+		m.remove(el)
+		return nil
+	}
+	m.lru.MoveToFront(el)
+	return e
+}
+
+func (m *Memory) remove(el *list.Element) {
+	m.lru.Remove(el)
+	delete(m.entries, el.Value.(*entry).k)
+}
+
+func (m *Memory) set(k entryKey, value []byte, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = m.now().Add(ttl)
+	}
+	value = append([]byte{}, value...)
+	if el, ok := m.entries[k]; ok {
+		e := el.Value.(*entry)
+		e.value, e.expires = value, expires
+		m.lru.MoveToFront(el)
+		return
+	}
+	m.entries[k] = m.lru.PushFront(&entry{k, value, expires})
+	if m.size > 0 && m.lru.Len() > m.size {
+		m.remove(m.lru.Back())
+	}
+	m.pending--
+	if m.pending <= 0 {
+		m.sweep()
+	}
+}
+
+// sweep removes every expired key; it's amortized over the keys added.
+func (m *Memory) sweep() {
+	now := m.now()
+	for el := m.lru.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*entry); !e.expires.IsZero() && !now.Before(e.expires) {
+			m.remove(el)
+		}
+		el = next
+	}
+	m.pending = m.lru.Len()
+}
+
+func (m *Memory) Get(ctx context.Context, session, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.lookup(entryKey{session, key})
+	if e == nil {
+		return nil, ezcx.ErrSessionKeyNotFound
+	}
+	return bytes.Clone(e.value), nil
+}
+
+func (m *Memory) Set(ctx context.Context, session, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(entryKey{session, key}, value, ttl)
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, session, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[entryKey{session, key}]; ok {
+		m.remove(el)
+	}
+	return nil
+}
+
+func (m *Memory) CompareAndSwap(ctx context.Context, session, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := entryKey{session, key}
+	e := m.lookup(k)
+	if (e == nil) != (old == nil) || (e != nil && !bytes.Equal(e.value, old)) {
+		return false, nil
+	}
+	m.set(k, new, ttl)
+	return true, nil
+}
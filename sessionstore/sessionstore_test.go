@@ -0,0 +1,121 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+	"github.com/googlecloudplatform/ezcx/ezcxtest"
+	"github.com/googlecloudplatform/ezcx/sessionstore/storetest"
+)
+
+func TestMemory(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) ezcx.SessionStore { return NewMemory(100) })
+}
+
+func TestFile(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) ezcx.SessionStore {
+		f, err := NewFile(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	})
+}
+
+func TestMemoryEviction(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2)
+	m.Set(ctx, "s", "a", []byte("1"), 0)
+	m.Set(ctx, "s", "b", []byte("2"), 0)
+	m.Get(ctx, "s", "a") // b is now least recently used
+	m.Set(ctx, "s", "c", []byte("3"), 0)
+	if m.Len() != 2 {
+		t.Errorf("Len() = %d", m.Len())
+	}
+	if _, err := m.Get(ctx, "s", "b"); !errors.Is(err, ezcx.ErrSessionKeyNotFound) {
+		t.Errorf("b wasn't evicted: %v", err)
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, err := m.Get(ctx, "s", k); err != nil {
+			t.Errorf("Get(%s): %v", k, err)
+		}
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := NewMemory(0)
+	m.now = func() time.Time { return now }
+	for _, k := range []string{"a", "b", "c"} {
+		m.Set(ctx, "s", k, []byte("1"), time.Minute)
+	}
+	now = now.Add(time.Hour)
+	// The expired keys are never read again but are still removed.
+	for _, k := range []string{"d", "e", "f"} {
+		m.Set(ctx, "s", k, []byte("1"), 0)
+	}
+	if m.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", m.Len())
+	}
+}
+
+func TestRequestSession(t *testing.T) {
+	type cart struct {
+		Items []string
+	}
+	st := NewMemory(0)
+	h := ezcx.Chain(func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		var items []string
+		err := ezcx.Update(req.Session(), "cart", func(c *cart) error {
+			c.Items = append(c.Items, req.GetText())
+			items = c.Items
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		res.AddTextResponse(items...)
+		return nil
+	}, ezcx.SessionStoreMiddleware(st, time.Hour))
+
+	b := ezcxtest.NewRequest().SessionID("abc")
+	for _, text := range []string{"latte", "scone"} {
+		req := b.Text(text).MustBuild()
+		res := req.InitializeResponse()
+		err := h(res, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text == "scone" {
+			ezcxtest.AssertText(t, res, "latte", "scone")
+		}
+	}
+	b2, err := st.Get(context.Background(), "abc", "cart")
+	if err != nil || string(b2) != `{"Items":["latte","scone"]}` {
+		t.Errorf("stored cart = %s, %v", b2, err)
+	}
+
+	req := ezcxtest.NewRequest().MustBuild()
+	_, err = req.Session().Get("cart", new(cart))
+	if !errors.Is(err, ezcx.ErrNoSessionStore) {
+		t.Errorf("without a store: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storetest is a conformance test suite for ezcx.SessionStore
+// implementations.
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/ezcx"
+)
+
+// TTL is the expiry used by the TTL tests.  Stores with a coarser expiry
+// resolution can raise it.
+var TTL = 50 * time.Millisecond
+
+// Run tests the SessionStore returned by newStore, which is called once
+// per subtest.
+func Run(t *testing.T, newStore func(t *testing.T) ezcx.SessionStore) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		f    func(t *testing.T, st ezcx.SessionStore)
+	}{
+		{"GetMissing", func(t *testing.T, st ezcx.SessionStore) {
+			_, err := st.Get(ctx, "s", "k")
+			if !errors.Is(err, ezcx.ErrSessionKeyNotFound) {
+				t.Errorf("Get = %v, want ErrSessionKeyNotFound", err)
+			}
+		}},
+		{"SetGet", func(t *testing.T, st ezcx.SessionStore) {
+			set(t, st, "s", "k", "v1", 0)
+			want(t, st, "s", "k", "v1")
+			set(t, st, "s", "k", "v2", 0)
+			want(t, st, "s", "k", "v2")
+		}},
+		{"EmptyValue", func(t *testing.T, st ezcx.SessionStore) {
+			set(t, st, "s", "k", "", 0)
+			want(t, st, "s", "k", "")
+		}},
+		{"Isolation", func(t *testing.T, st ezcx.SessionStore) {
+			set(t, st, "s1", "k", "a", 0)
+			set(t, st, "s2", "k", "b", 0)
+			set(t, st, "s1", "k2", "c", 0)
+			want(t, st, "s1", "k", "a")
+			want(t, st, "s2", "k", "b")
+			want(t, st, "s1", "k2", "c")
+			wantMissing(t, st, "s2", "k2")
+		}},
+		{"AwkwardNames", func(t *testing.T, st ezcx.SessionStore) {
+			session := "projects/p/locations/l/agents/a/sessions/../../x"
+			set(t, st, session, "a/b\x00c", "v", 0)
+			want(t, st, session, "a/b\x00c", "v")
+		}},
+		{"Delete", func(t *testing.T, st ezcx.SessionStore) {
+			set(t, st, "s", "k", "v", 0)
+			err := st.Delete(ctx, "s", "k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantMissing(t, st, "s", "k")
+			err = st.Delete(ctx, "s", "k")
+			if err != nil {
+				t.Errorf("deleting a missing key: %v", err)
+			}
+		}},
+		{"TTL", func(t *testing.T, st ezcx.SessionStore) {
+			set(t, st, "s", "short", "v", TTL)
+			set(t, st, "s", "forever", "v", 0)
+			want(t, st, "s", "short", "v")
+			time.Sleep(2 * TTL)
+			wantMissing(t, st, "s", "short")
+			want(t, st, "s", "forever", "v")
+		}},
+		{"SetResetsTTL", func(t *testing.T, st ezcx.SessionStore) {
+			set(t, st, "s", "k", "v", TTL)
+			set(t, st, "s", "k", "v", 0)
+			time.Sleep(2 * TTL)
+			want(t, st, "s", "k", "v")
+		}},
+		{"CompareAndSwap", func(t *testing.T, st ezcx.SessionStore) {
+			cas(t, st, "k", nil, "v1", true)
+			cas(t, st, "k", nil, "v2", false)
+			cas(t, st, "k", []byte("other"), "v2", false)
+			want(t, st, "s", "k", "v1")
+			cas(t, st, "k", []byte("v1"), "v2", true)
+			want(t, st, "s", "k", "v2")
+		}},
+		{"CompareAndSwapExpired", func(t *testing.T, st ezcx.SessionStore) {
+			set(t, st, "s", "k", "v", TTL)
+			time.Sleep(2 * TTL)
+			cas(t, st, "k", []byte("v"), "v2", false)
+			cas(t, st, "k", nil, "v2", true)
+		}},
+		{"ConcurrentCompareAndSwap", func(t *testing.T, st ezcx.SessionStore) {
+			const n = 20
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						cur, err := st.Get(ctx, "s", "counter")
+						if errors.Is(err, ezcx.ErrSessionKeyNotFound) {
+							cur = nil
+						} else if err != nil {
+							t.Error(err)
+							return
+						}
+						c, _ := strconv.Atoi(string(cur))
+						ok, err := st.CompareAndSwap(ctx, "s", "counter", cur, []byte(strconv.Itoa(c+1)), 0)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						if ok {
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			want(t, st, "s", "counter", strconv.Itoa(n))
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.f(t, newStore(t))
+		})
+	}
+}
+
+func set(t *testing.T, st ezcx.SessionStore, session, key, value string, ttl time.Duration) {
+	t.Helper()
+	err := st.Set(context.Background(), session, key, []byte(value), ttl)
+	if err != nil {
+		t.Fatalf("Set(%q, %q): %v", session, key, err)
+	}
+}
+
+func want(t *testing.T, st ezcx.SessionStore, session, key, value string) {
+	t.Helper()
+	got, err := st.Get(context.Background(), session, key)
+	if err != nil {
+		t.Fatalf("Get(%q, %q): %v", session, key, err)
+	}
+	if !bytes.Equal(got, []byte(value)) {
+		t.Errorf("Get(%q, %q) = %q, want %q", session, key, got, value)
+	}
+}
+
+func wantMissing(t *testing.T, st ezcx.SessionStore, session, key string) {
+	t.Helper()
+	got, err := st.Get(context.Background(), session, key)
+	if !errors.Is(err, ezcx.ErrSessionKeyNotFound) {
+		t.Errorf("Get(%q, %q) = %q, %v, want ErrSessionKeyNotFound", session, key, got, err)
+	}
+}
+
+func cas(t *testing.T, st ezcx.SessionStore, key string, old []byte, new string, wantOK bool) {
+	t.Helper()
+	ok, err := st.CompareAndSwap(context.Background(), "s", key, old, []byte(new), 0)
+	if err != nil {
+		t.Fatalf("CompareAndSwap(%q, %q): %v", old, new, err)
+	}
+	if ok != wantOK {
+		t.Errorf("CompareAndSwap(%q, %q) = %v, want %v", old, new, ok, wantOK)
+	}
+}
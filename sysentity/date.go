@@ -0,0 +1,183 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysentity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Date is an @sys.date value: a calendar date without a time zone.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateOf returns the date of t in t's location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{y, m, d}
+}
+
+// ParseDate parses a date in the form 2006-01-02.
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return Date{}, err
+	}
+	return DateOf(t), nil
+}
+
+// String returns the date in the form 2006-01-02.
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// IsValid reports whether d is a real date e.g. not February 30th.
+func (d Date) IsValid() bool {
+	return DateOf(d.In(time.UTC)) == d
+}
+
+// In returns midnight at the start of d in loc.
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// AddDays returns d plus n days.
+func (d Date) AddDays(n int) Date {
+	return DateOf(d.In(time.UTC).AddDate(0, 0, n))
+}
+
+func (d Date) Param() any {
+	return map[string]any{
+		"year":  float64(d.Year),
+		"month": float64(d.Month),
+		"day":   float64(d.Day),
+	}
+}
+
+func (d *Date) FromParam(v any) error {
+	m, err := fields(v)
+	if err != nil {
+		return err
+	}
+	var month int
+	d.Year, err = integer(m, "year")
+	if err == nil {
+		month, err = integer(m, "month")
+	}
+	if err == nil {
+		d.Day, err = integer(m, "day")
+	}
+	d.Month = time.Month(month)
+	return err
+}
+
+func (d Date) MarshalJSON() ([]byte, error)  { return marshal(d) }
+func (d *Date) UnmarshalJSON(b []byte) error { return unmarshal(b, d) }
+
+// Time is an @sys.time value: a time of day without a time zone.
+type Time struct {
+	Hour       int
+	Minute     int
+	Second     int
+	Nanosecond int
+}
+
+// TimeOf returns the time of day of t in t's location.
+func TimeOf(t time.Time) Time {
+	return Time{t.Hour(), t.Minute(), t.Second(), t.Nanosecond()}
+}
+
+// String returns the time in the form 15:04:05.
+func (t Time) String() string {
+	return fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+}
+
+// On returns the time t on the date d in loc.
+func (t Time) On(d Date, loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, t.Hour, t.Minute, t.Second, t.Nanosecond, loc)
+}
+
+func (t Time) Param() any {
+	return map[string]any{
+		"hours":   float64(t.Hour),
+		"minutes": float64(t.Minute),
+		"seconds": float64(t.Second),
+		"nanos":   float64(t.Nanosecond),
+	}
+}
+
+func (t *Time) FromParam(v any) error {
+	m, err := fields(v)
+	if err != nil {
+		return err
+	}
+	t.Hour, err = integer(m, "hours")
+	if err == nil {
+		t.Minute, err = integer(m, "minutes")
+	}
+	if err == nil {
+		t.Second, err = integer(m, "seconds")
+	}
+	if err == nil {
+		t.Nanosecond, err = integer(m, "nanos")
+	}
+	return err
+}
+
+func (t Time) MarshalJSON() ([]byte, error)  { return marshal(t) }
+func (t *Time) UnmarshalJSON(b []byte) error { return unmarshal(b, t) }
+
+// DateTime is an @sys.date-time value.
+type DateTime struct {
+	Date
+	Time
+}
+
+// DateTimeOf returns the date and time of t in t's location.
+func DateTimeOf(t time.Time) DateTime {
+	return DateTime{DateOf(t), TimeOf(t)}
+}
+
+// String returns the date and time in the form 2006-01-02T15:04:05.
+func (dt DateTime) String() string {
+	return dt.Date.String() + "T" + dt.Time.String()
+}
+
+// In returns dt as a time.Time in loc.
+func (dt DateTime) In(loc *time.Location) time.Time {
+	return dt.Time.On(dt.Date, loc)
+}
+
+func (dt DateTime) Param() any {
+	m := dt.Date.Param().(map[string]any)
+	for k, v := range dt.Time.Param().(map[string]any) {
+		m[k] = v
+	}
+	return m
+}
+
+func (dt *DateTime) FromParam(v any) error {
+	err := dt.Date.FromParam(v)
+	if err != nil {
+		return err
+	}
+	return dt.Time.FromParam(v)
+}
+
+func (dt DateTime) MarshalJSON() ([]byte, error)  { return marshal(dt) }
+func (dt *DateTime) UnmarshalJSON(b []byte) error { return unmarshal(b, dt) }
@@ -0,0 +1,123 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysentity
+
+import (
+	"strings"
+)
+
+// Person is an @sys.person value.
+type Person struct {
+	Name string
+}
+
+func (p Person) String() string {
+	return p.Name
+}
+
+func (p Person) Param() any {
+	return map[string]any{"name": p.Name}
+}
+
+func (p *Person) FromParam(v any) error {
+	// Older agents send the name on its own.
+	if s, ok := v.(string); ok {
+		p.Name = s
+		return nil
+	}
+	f, err := fields(v)
+	if err != nil {
+		return err
+	}
+	p.Name, err = str(f, "name")
+	return err
+}
+
+func (p Person) MarshalJSON() ([]byte, error)  { return marshal(p) }
+func (p *Person) UnmarshalJSON(b []byte) error { return unmarshal(b, p) }
+
+// Location is an @sys.location value.  Fields the end-user didn't say are
+// empty.
+type Location struct {
+	Country       string
+	AdminArea     string
+	SubadminArea  string
+	City          string
+	Island        string
+	ZipCode       string
+	StreetAddress string
+	BusinessName  string
+	Shortcut      string
+}
+
+func (l *Location) fields() []struct {
+	key string
+	val *string
+} {
+	return []struct {
+		key string
+		val *string
+	}{
+		{"country", &l.Country},
+		{"admin-area", &l.AdminArea},
+		{"subadmin-area", &l.SubadminArea},
+		{"city", &l.City},
+		{"island", &l.Island},
+		{"zip-code", &l.ZipCode},
+		{"street-address", &l.StreetAddress},
+		{"business-name", &l.BusinessName},
+		{"shortcut", &l.Shortcut},
+	}
+}
+
+// String returns the location's non-empty fields, most specific first,
+// separated by commas.
+func (l Location) String() string {
+	fs := l.fields()
+	var parts []string
+	for i := len(fs) - 1; i >= 0; i-- {
+		if *fs[i].val != "" {
+			parts = append(parts, *fs[i].val)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (l Location) Param() any {
+	m := make(map[string]any)
+	for _, f := range l.fields() {
+		if *f.val != "" {
+			m[f.key] = *f.val
+		}
+	}
+	return m
+}
+
+func (l *Location) FromParam(v any) error {
+	m, err := fields(v)
+	if err != nil {
+		return err
+	}
+	for _, f := range l.fields() {
+		*f.val, err = str(m, f.key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l Location) MarshalJSON() ([]byte, error)  { return marshal(l) }
+func (l *Location) UnmarshalJSON(b []byte) error { return unmarshal(b, l) }
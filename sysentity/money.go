@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysentity
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Money is an @sys.unit-currency value.  Currency is an ISO 4217 code e.g.
+// USD.
+type Money struct {
+	Amount   float64
+	Currency string
+}
+
+// String returns the amount followed by the currency e.g. "12.5 USD".
+func (m Money) String() string {
+	return strconv.FormatFloat(m.Amount, 'f', -1, 64) + " " + m.Currency
+}
+
+func (m Money) Param() any {
+	return map[string]any{"amount": m.Amount, "currency": m.Currency}
+}
+
+func (m *Money) FromParam(v any) error {
+	f, err := fields(v)
+	if err != nil {
+		return err
+	}
+	m.Amount, err = number(f, "amount")
+	if err != nil {
+		return err
+	}
+	m.Currency, err = str(f, "currency")
+	if err != nil {
+		return err
+	}
+	if !isCurrencyCode(m.Currency) {
+		return fmt.Errorf("currency: %q isn't an ISO 4217 code", m.Currency)
+	}
+	return nil
+}
+
+func (m Money) MarshalJSON() ([]byte, error)  { return marshal(m) }
+func (m *Money) UnmarshalJSON(b []byte) error { return unmarshal(b, m) }
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Quantity is an amount with a unit: an @sys.duration, @sys.age,
+// @sys.temperature, @sys.unit-length or @sys.unit-weight value.
+type Quantity struct {
+	Amount float64
+	Unit   string
+}
+
+// durationUnits are the @sys.duration units with a fixed length.
+var durationUnits = map[string]time.Duration{
+	"ms":  time.Millisecond,
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+	"day": 24 * time.Hour,
+	"wk":  7 * 24 * time.Hour,
+}
+
+// QuantityOf returns d as an @sys.duration value in seconds.
+func QuantityOf(d time.Duration) Quantity {
+	return Quantity{d.Seconds(), "s"}
+}
+
+// Duration returns q as a time.Duration.  It fails for units without a
+// fixed length, e.g. months.
+func (q Quantity) Duration() (time.Duration, error) {
+	u, ok := durationUnits[q.Unit]
+	if !ok {
+		return 0, fmt.Errorf("sysentity: can't convert unit %q to a duration", q.Unit)
+	}
+	return time.Duration(q.Amount * float64(u)), nil
+}
+
+// String returns the amount followed by the unit e.g. "30 min".
+func (q Quantity) String() string {
+	return strconv.FormatFloat(q.Amount, 'f', -1, 64) + " " + q.Unit
+}
+
+func (q Quantity) Param() any {
+	return map[string]any{"amount": q.Amount, "unit": q.Unit}
+}
+
+func (q *Quantity) FromParam(v any) error {
+	f, err := fields(v)
+	if err != nil {
+		return err
+	}
+	q.Amount, err = number(f, "amount")
+	if err != nil {
+		return err
+	}
+	q.Unit, err = str(f, "unit")
+	return err
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error)  { return marshal(q) }
+func (q *Quantity) UnmarshalJSON(b []byte) error { return unmarshal(b, q) }
@@ -0,0 +1,91 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysentity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultCallingCode is the country calling code FromParam and
+// UnmarshalJSON use for phone numbers without one.
+var DefaultCallingCode = "1"
+
+// ErrPhoneNumber is returned for strings that aren't phone numbers.
+var ErrPhoneNumber = errors.New("sysentity: invalid phone number")
+
+// Phone is an @sys.phone-number value in E.164 form e.g. +14155550100.
+type Phone string
+
+// ParsePhone returns s in E.164 form.  Spaces, dashes, dots and parentheses
+// are ignored.  Numbers starting with + or the international prefix 00
+// keep their calling code; others are national numbers, which get
+// callingCode (e.g. "1" or "44") in place of a trunk prefix.
+func ParsePhone(s, callingCode string) (Phone, error) {
+	var digits strings.Builder
+	for i, r := range strings.TrimSpace(s) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+		case strings.ContainsRune(" -.()", r):
+		default:
+			return "", fmt.Errorf("%w: %q", ErrPhoneNumber, s)
+		}
+	}
+	n := digits.String()
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(s), "+"):
+	case strings.HasPrefix(n, "00"):
+		n = n[2:]
+	case callingCode == "1" && len(n) == 11 && n[0] == '1':
+		// North American numbers may be dialled with the calling code.
+	default:
+		if callingCode == "" {
+			return "", fmt.Errorf("%w: %q has no calling code", ErrPhoneNumber, s)
+		}
+		n = callingCode + strings.TrimPrefix(n, "0")
+	}
+	// E.164 numbers are at most 15 digits; the shortest are 8.
+	if len(n) < 8 || len(n) > 15 || n[0] == '0' {
+		return "", fmt.Errorf("%w: %q", ErrPhoneNumber, s)
+	}
+	return Phone("+" + n), nil
+}
+
+func (p Phone) String() string {
+	return string(p)
+}
+
+func (p Phone) Param() any {
+	return string(p)
+}
+
+func (p *Phone) FromParam(v any) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("want a string, got %T", v)
+	}
+	n, err := ParsePhone(s, DefaultCallingCode)
+	if err != nil {
+		return err
+	}
+	*p = n
+	return nil
+}
+
+func (p Phone) MarshalJSON() ([]byte, error)  { return marshal(p) }
+func (p *Phone) UnmarshalJSON(b []byte) error { return unmarshal(b, p) }
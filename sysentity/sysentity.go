@@ -0,0 +1,124 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sysentity converts Dialogflow CX system entity values to and from
+// Go types.
+//
+// System entities reach the webhook as parameter values, e.g. an @sys.date
+// arrives in GetSessionParameters as map[string]any{"year": 2022.0,
+// "month": 8.0, "day": 1.0}.  Each type here reads that form with FromParam
+// and writes it back with Param, so handlers can set it as a parameter:
+//
+//	var d sysentity.Date
+//	ok, err := sysentity.Get(req.GetSessionParameters(), "date", &d)
+//	...
+//	err = res.AddSessionParameters(map[string]any{"date": d.AddDays(1).Param()})
+//
+// The types also implement json.Marshaler and json.Unmarshaler using the
+// same form.
+package sysentity
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Value is a system entity value.
+type Value interface {
+	// FromParam sets the value from a parameter value.
+	FromParam(v any) error
+	// Param returns the value as a parameter value.
+	Param() any
+}
+
+// Get sets v from the parameter name in params.  It reports false if the
+// parameter is missing or null.
+func Get(params map[string]any, name string, v Value) (bool, error) {
+	p, ok := params[name]
+	if !ok || p == nil {
+		return false, nil
+	}
+	err := v.FromParam(p)
+	if err != nil {
+		return false, fmt.Errorf("sysentity: parameter %q: %w", name, err)
+	}
+	return true, nil
+}
+
+// Set sets the parameter name in params to v.
+func Set(params map[string]any, name string, v interface{ Param() any }) {
+	params[name] = v.Param()
+}
+
+func fields(v any) (map[string]any, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("want an object, got %T", v)
+	}
+	return m, nil
+}
+
+func number(m map[string]any, key string) (float64, error) {
+	switch n := m[key].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	default:
+		return 0, fmt.Errorf("%s: want a number, got %T", key, n)
+	}
+}
+
+func integer(m map[string]any, key string) (int, error) {
+	n, err := number(m, key)
+	if err != nil {
+		return 0, err
+	}
+	if n != math.Trunc(n) {
+		return 0, fmt.Errorf("%s: %v isn't an integer", key, n)
+	}
+	return int(n), nil
+}
+
+func str(m map[string]any, key string) (string, error) {
+	switch s := m[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return s, nil
+	default:
+		return "", fmt.Errorf("%s: want a string, got %T", key, s)
+	}
+}
+
+func marshal(v interface{ Param() any }) ([]byte, error) {
+	return json.Marshal(v.Param())
+}
+
+func unmarshal(b []byte, v Value) error {
+	var p any
+	err := json.Unmarshal(b, &p)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return nil
+	}
+	return v.FromParam(p)
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysentity
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		param any
+		v     Value
+		want  Value
+	}{
+		{
+			map[string]any{"year": 2022.0, "month": 8.0, "day": 1.0},
+			new(Date), &Date{2022, time.August, 1},
+		},
+		{
+			map[string]any{"hours": 13.0, "minutes": 30.0, "seconds": 0.0, "nanos": 0.0},
+			new(Time), &Time{13, 30, 0, 0},
+		},
+		{
+			map[string]any{"year": 2022.0, "month": 8.0, "day": 1.0, "hours": 9.0, "minutes": 5.0, "seconds": 0.0, "nanos": 0.0},
+			new(DateTime), &DateTime{Date{2022, time.August, 1}, Time{9, 5, 0, 0}},
+		},
+		{
+			map[string]any{"amount": 12.5, "currency": "USD"},
+			new(Money), &Money{12.5, "USD"},
+		},
+		{
+			map[string]any{"amount": 30.0, "unit": "min"},
+			new(Quantity), &Quantity{30, "min"},
+		},
+		{
+			map[string]any{"name": "Ada"},
+			new(Person), &Person{"Ada"},
+		},
+		{
+			map[string]any{"city": "Sunnyvale", "admin-area": "CA", "country": "US"},
+			new(Location), &Location{Country: "US", AdminArea: "CA", City: "Sunnyvale"},
+		},
+		{
+			"+14155550100",
+			new(Phone), ptr(Phone("+14155550100")),
+		},
+	} {
+		err := tc.v.FromParam(tc.param)
+		if err != nil {
+			t.Errorf("%T: %v", tc.v, err)
+			continue
+		}
+		if !reflect.DeepEqual(tc.v, tc.want) {
+			t.Errorf("FromParam(%v) = %+v, want %+v", tc.param, tc.v, tc.want)
+		}
+		if got := tc.v.Param(); !reflect.DeepEqual(got, tc.param) {
+			t.Errorf("%T.Param() = %v, want %v", tc.v, got, tc.param)
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestGetSet(t *testing.T) {
+	params := map[string]any{"missing": nil, "bad": "tomorrow"}
+	var d Date
+	ok, err := Get(params, "date", &d)
+	if ok || err != nil {
+		t.Errorf("Get(date) = %v, %v", ok, err)
+	}
+	ok, err = Get(params, "missing", &d)
+	if ok || err != nil {
+		t.Errorf("Get(missing) = %v, %v", ok, err)
+	}
+	_, err = Get(params, "bad", &d)
+	if err == nil {
+		t.Error("Get(bad) succeeded")
+	}
+	Set(params, "date", Date{2022, time.December, 31}.AddDays(1))
+	ok, err = Get(params, "date", &d)
+	if !ok || err != nil || d.String() != "2023-01-01" {
+		t.Errorf("Get(date) = %v, %v, %v", d, ok, err)
+	}
+}
+
+func TestDate(t *testing.T) {
+	d, err := ParseDate("2024-02-29")
+	if err != nil || !d.IsValid() {
+		t.Fatalf("ParseDate = %v, %v", d, err)
+	}
+	if (Date{2023, time.February, 29}).IsValid() {
+		t.Error("2023-02-29 is valid")
+	}
+	loc := time.FixedZone("PST", -8*60*60)
+	got := Time{9, 30, 0, 0}.On(d, loc)
+	if want := time.Date(2024, 2, 29, 9, 30, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("On = %v, want %v", got, want)
+	}
+	if dt := DateTimeOf(got); dt.In(loc) != got || dt.String() != "2024-02-29T09:30:00" {
+		t.Errorf("DateTimeOf = %v", dt)
+	}
+	err = new(Date).FromParam(map[string]any{"year": 2022.5})
+	if err == nil {
+		t.Error("fractional year accepted")
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	d, err := Quantity{1.5, "h"}.Duration()
+	if err != nil || d != 90*time.Minute {
+		t.Errorf("Duration = %v, %v", d, err)
+	}
+	_, err = Quantity{2, "mo"}.Duration()
+	if err == nil {
+		t.Error("months converted to a duration")
+	}
+	if q := QuantityOf(90 * time.Second); q != (Quantity{90, "s"}) {
+		t.Errorf("QuantityOf = %v", q)
+	}
+	err = new(Money).FromParam(map[string]any{"amount": 1.0, "currency": "$"})
+	if err == nil {
+		t.Error("currency $ accepted")
+	}
+}
+
+func TestParsePhone(t *testing.T) {
+	for _, tc := range []struct {
+		s, callingCode string
+		want           Phone
+	}{
+		{"(415) 555-0100", "1", "+14155550100"},
+		{"1 415 555 0100", "1", "+14155550100"},
+		{"+44 20 7946 0958", "1", "+442079460958"},
+		{"0044 20 7946 0958", "1", "+442079460958"},
+		{"020 7946 0958", "44", "+442079460958"},
+	} {
+		got, err := ParsePhone(tc.s, tc.callingCode)
+		if err != nil || got != tc.want {
+			t.Errorf("ParsePhone(%q, %q) = %q, %v, want %q", tc.s, tc.callingCode, got, err, tc.want)
+		}
+	}
+	for _, s := range []string{"555-01", "call me", "+1 415 555 0100 0100 0100"} {
+		_, err := ParsePhone(s, "1")
+		if !errors.Is(err, ErrPhoneNumber) {
+			t.Errorf("ParsePhone(%q) = %v", s, err)
+		}
+	}
+	_, err := ParsePhone("020 7946 0958", "")
+	if !errors.Is(err, ErrPhoneNumber) {
+		t.Errorf("ParsePhone without calling code = %v", err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var form struct {
+		Date   Date     `json:"date"`
+		Cost   Money    `json:"cost"`
+		Phone  Phone    `json:"phone"`
+		Person *Person  `json:"person"`
+		Wait   Quantity `json:"wait"`
+	}
+	in := `{"date":{"year":2022,"month":8,"day":1},"cost":{"amount":3.5,"currency":"EUR"},"phone":"415-555-0100","person":{"name":"Ada"},"wait":null}`
+	err := json.Unmarshal([]byte(in), &form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Date.String() != "2022-08-01" || form.Cost.String() != "3.5 EUR" || form.Phone != "+14155550100" || form.Person.Name != "Ada" {
+		t.Errorf("form = %+v", form)
+	}
+	b, err := json.Marshal(form.Date)
+	if err != nil || string(b) != `{"day":1,"month":8,"year":2022}` {
+		t.Errorf("Marshal = %s, %v", b, err)
+	}
+}
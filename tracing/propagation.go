@@ -0,0 +1,105 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CloudTraceContextHeader is the legacy Google Cloud trace header.  Cloud Run
+// and the Google Front End populate it alongside (or instead of) traceparent.
+const CloudTraceContextHeader = "X-Cloud-Trace-Context"
+
+// CloudTraceContext is a propagation.TextMapPropagator for the
+// X-Cloud-Trace-Context header; its format is TRACE_ID/SPAN_ID;o=OPTIONS where
+// TRACE_ID is 32 hex characters, SPAN_ID is a decimal uint64 and o=1 indicates
+// the trace is sampled.
+type CloudTraceContext struct{}
+
+var _ propagation.TextMapPropagator = CloudTraceContext{}
+
+// Inject sets the X-Cloud-Trace-Context header from the span context in ctx.
+func (CloudTraceContext) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	spanID := sc.SpanID()
+	opts := 0
+	if sc.IsSampled() {
+		opts = 1
+	}
+	carrier.Set(CloudTraceContextHeader,
+		fmt.Sprintf("%s/%d;o=%d", sc.TraceID(), binary.BigEndian.Uint64(spanID[:]), opts))
+}
+
+// Extract returns a copy of ctx carrying the remote span context found in the
+// X-Cloud-Trace-Context header.  If the header is missing or malformed, ctx is
+// returned unchanged.
+func (CloudTraceContext) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	sc, ok := ParseCloudTraceContext(carrier.Get(CloudTraceContextHeader))
+	if !ok {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Fields returns the header keys used by CloudTraceContext.
+func (CloudTraceContext) Fields() []string {
+	return []string{CloudTraceContextHeader}
+}
+
+// ParseCloudTraceContext parses the value of an X-Cloud-Trace-Context header.
+// The returned bool is false when the value doesn't hold a valid trace and
+// span ID pair.
+func ParseCloudTraceContext(v string) (trace.SpanContext, bool) {
+	if v == "" {
+		return trace.SpanContext{}, false
+	}
+	v, opts, _ := strings.Cut(v, ";")
+	traceHex, spanDec, ok := strings.Cut(v, "/")
+	if !ok {
+		return trace.SpanContext{}, false
+	}
+	traceID, err := trace.TraceIDFromHex(traceHex)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	n, err := strconv.ParseUint(spanDec, 10, 64)
+	if err != nil || n == 0 {
+		return trace.SpanContext{}, false
+	}
+	var spanID trace.SpanID
+	binary.BigEndian.PutUint64(spanID[:], n)
+
+	var flags trace.TraceFlags
+	if opts == "o=1" {
+		flags = trace.FlagsSampled
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	})
+	return sc, sc.IsValid()
+}
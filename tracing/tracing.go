@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing provides OpenTelemetry tracing for ezcx handlers.
+//
+// The tracing Middleware starts a span for each webhook call; the span is
+// a child of the trace found in the incoming traceparent or
+// X-Cloud-Trace-Context header and is flowed down via (*WebhookRequest).Context
+// so that backend calls made with req.Context() join the same trace.
+package tracing
+
+import (
+	"github.com/googlecloudplatform/ezcx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope used for spans created by ezcx.
+const ScopeName = "github.com/googlecloudplatform/ezcx/tracing"
+
+// Span attribute keys populated from the WebhookRequest.
+const (
+	SessionIDKey       = attribute.Key("ezcx.session.id")
+	TagKey             = attribute.Key("ezcx.fulfillment.tag")
+	PageDisplayNameKey = attribute.Key("ezcx.page.display_name")
+	IntentKey          = attribute.Key("ezcx.intent.display_name")
+	LanguageCodeKey    = attribute.Key("ezcx.language_code")
+)
+
+// Propagator extracts the incoming trace from either the W3C traceparent
+// header or the X-Cloud-Trace-Context header; traceparent wins when both
+// are present.
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	CloudTraceContext{},
+	propagation.TraceContext{},
+)
+
+// Middleware returns an ezcx.Middleware that starts a server span per webhook
+// call using the provided TracerProvider.  If tp is nil, the global
+// TracerProvider is used.
+func Middleware(tp trace.TracerProvider) ezcx.Middleware {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	tracer := tp.Tracer(ScopeName)
+	return func(next ezcx.HandlerFunc) ezcx.HandlerFunc {
+		return func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+			ctx := req.Context()
+			if r := req.Request(); r != nil {
+				ctx = Propagator.Extract(ctx, propagation.HeaderCarrier(r.Header))
+			}
+			ctx, span := tracer.Start(ctx, spanName(req),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(Attributes(req)...),
+			)
+			defer span.End()
+			req.SetContext(ctx)
+
+			err := next(res, req)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
+
+// SpanFromRequest returns the span flowed down by Middleware.  If there's no
+// span, a no-op span is returned.
+func SpanFromRequest(req *ezcx.WebhookRequest) trace.Span {
+	return trace.SpanFromContext(req.Context())
+}
+
+// Attributes returns the span attributes describing the WebhookRequest.
+// Empty values are omitted.
+func Attributes(req *ezcx.WebhookRequest) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, 5)
+	add := func(k attribute.Key, v string) {
+		if v != "" {
+			attrs = append(attrs, k.String(v))
+		}
+	}
+	add(SessionIDKey, req.SessionID())
+	add(TagKey, req.GetFulfillmentInfo().GetTag())
+	add(PageDisplayNameKey, req.GetPageInfo().GetDisplayName())
+	add(IntentKey, req.GetIntentInfo().GetDisplayName())
+	add(LanguageCodeKey, req.GetLanguageCode())
+	return attrs
+}
+
+func spanName(req *ezcx.WebhookRequest) string {
+	tag := req.GetFulfillmentInfo().GetTag()
+	if tag == "" {
+		return "ezcx.webhook"
+	}
+	return "ezcx.webhook " + tag
+}
+
+// NewTracerProvider returns a TracerProvider that batches spans to the
+// provided exporter.  Any additional options are applied after the batcher.
+func NewTracerProvider(exp sdktrace.SpanExporter, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	opts = append([]sdktrace.TracerProviderOption{sdktrace.WithBatcher(exp)}, opts...)
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// NewInMemoryTracerProvider returns a TracerProvider that synchronously
+// exports every span to an in-memory exporter.  Provided for testing.
+func NewInMemoryTracerProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	return tp, exp
+}
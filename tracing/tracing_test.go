@@ -0,0 +1,151 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/googlecloudplatform/ezcx"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddlewareTraceparent(t *testing.T) {
+	tp, exp := NewInMemoryTracerProvider()
+	var handlerSpan trace.SpanContext
+	h := ezcx.Chain(func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		handlerSpan = SpanFromRequest(req).SpanContext()
+		res.AddTextResponse("traced")
+		return nil
+	}, Middleware(tp))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(sample))
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set(CloudTraceContextHeader, "11111111111111111111111111111111/1;o=1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if got := span.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID: got %s", got)
+	}
+	if got := span.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span ID: got %s", got)
+	}
+	if handlerSpan.SpanID() != span.SpanContext.SpanID() {
+		t.Errorf("handler did not see the webhook span")
+	}
+	if span.Name != "ezcx.webhook nb-cohorts" {
+		t.Errorf("span name: got %q", span.Name)
+	}
+
+	want := map[string]string{
+		string(SessionIDKey):       "0591c1-9e2-06b-79c-49e9affb8",
+		string(TagKey):             "nb-cohorts",
+		string(PageDisplayNameKey): "get-national-benchmarks",
+		string(IntentKey):          "benchmarks",
+		string(LanguageCodeKey):    "en",
+	}
+	for _, kv := range span.Attributes {
+		k := string(kv.Key)
+		if v, ok := want[k]; ok {
+			if kv.Value.AsString() != v {
+				t.Errorf("attribute %s: got %q, want %q", k, kv.Value.AsString(), v)
+			}
+			delete(want, k)
+		}
+	}
+	for k := range want {
+		t.Errorf("missing attribute %s", k)
+	}
+}
+
+func TestMiddlewareCloudTraceContext(t *testing.T) {
+	tp, exp := NewInMemoryTracerProvider()
+	h := ezcx.Chain(func(res *ezcx.WebhookResponse, req *ezcx.WebhookRequest) error {
+		return errors.New("backend unavailable")
+	}, Middleware(tp))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(sample))
+	r.Header.Set(CloudTraceContextHeader, "105445aa7843bc8bf206b12000100000/1;o=1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if got := span.SpanContext.TraceID().String(); got != "105445aa7843bc8bf206b12000100000" {
+		t.Errorf("trace ID: got %s", got)
+	}
+	if got := span.Parent.SpanID().String(); got != "0000000000000001" {
+		t.Errorf("parent span ID: got %s", got)
+	}
+	if span.Status.Code != codes.Error {
+		t.Errorf("status: got %v, want Error", span.Status.Code)
+	}
+}
+
+func TestParseCloudTraceContext(t *testing.T) {
+	tests := []struct {
+		in      string
+		ok      bool
+		sampled bool
+	}{
+		{"105445aa7843bc8bf206b12000100000/1;o=1", true, true},
+		{"105445aa7843bc8bf206b12000100000/1;o=0", true, false},
+		{"105445aa7843bc8bf206b12000100000/18446744073709551615", true, false},
+		{"105445aa7843bc8bf206b12000100000", false, false},
+		{"105445aa7843bc8bf206b12000100000/0;o=1", false, false},
+		{"not-a-trace/1;o=1", false, false},
+		{"", false, false},
+	}
+	for _, tc := range tests {
+		sc, ok := ParseCloudTraceContext(tc.in)
+		if ok != tc.ok {
+			t.Errorf("%q: got ok=%v, want %v", tc.in, ok, tc.ok)
+			continue
+		}
+		if ok && sc.IsSampled() != tc.sampled {
+			t.Errorf("%q: got sampled=%v, want %v", tc.in, sc.IsSampled(), tc.sampled)
+		}
+	}
+}
+
+var sample = `{
+"detectIntentResponseId": "e12be281-028f-4a6b-95c6-9850a27542f1",
+"intentInfo": {
+	"displayName": "benchmarks"
+},
+"pageInfo": {
+	"currentPage": "projects/oktony-cx/locations/global/agents/c5e716ba-9b90-4edc-a792-2ee7dd24b428/flows/2e387ccd-a8f4-4a0e-9cb8-17bad040d8fe/pages/b34fda0b-0769-4f42-b91c-ff38e4bc1268",
+	"displayName": "get-national-benchmarks"
+},
+"sessionInfo": {
+	"session": "projects/oktony-cx/locations/global/agents/c5e716ba-9b90-4edc-a792-2ee7dd24b428/sessions/0591c1-9e2-06b-79c-49e9affb8"
+},
+"fulfillmentInfo": {
+	"tag": "nb-cohorts"
+},
+"text": "65+",
+"languageCode": "en"
+}`
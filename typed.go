@@ -0,0 +1,302 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+)
+
+// ErrMissingParam is wrapped by BindErrors for missing required parameters.
+var ErrMissingParam = errors.New("ezcx: missing required parameter")
+
+// DefaultBindErrorText is the response to requests that can't be bound
+// when a TypedHandler has no OnBindError.
+var DefaultBindErrorText = "Sorry, something went wrong.  Please try again."
+
+// BindError is returned when a request parameter can't be bound to a
+// TypedHandler's input.
+type BindError struct {
+	Field string // Go field name
+	Param string // parameter name
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("ezcx: binding parameter %q to %s: %v", e.Param, e.Field, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// Renderer is implemented by TypedHandler outputs that write to the
+// WebhookResponse themselves, e.g. Reply.
+type Renderer interface {
+	Render(res *WebhookResponse, req *WebhookRequest) error
+}
+
+// TypedHandler adapts a func(context.Context, In) (Out, error) into a
+// HandlerFunc.
+//
+// In is a struct whose fields are bound from the request by their cx tag,
+// `cx:"name[,source][,required]"`, where source is one of form, intent,
+// session or payload.  Without a source, the form parameters are tried,
+// then the matched intent's, then the session's.  Values are converted via
+// JSON, so fields may be of any type with a matching JSON form, e.g.
+// float64 for @sys.number or sysentity.Date for @sys.date.
+//
+// Out is rendered into the WebhookResponse: if it's a Renderer, its
+// Render method is called, and its fields with a cx tag are set as session
+// parameters (`cx:"name[,omitempty]"`).
+type TypedHandler[In, Out any] struct {
+	f func(context.Context, In) (Out, error)
+	// OnBindError is called when the request can't be bound to In.  If
+	// nil, the error is logged and the response is DefaultBindErrorText.
+	OnBindError func(res *WebhookResponse, req *WebhookRequest, err *BindError) error
+}
+
+// Typed returns a TypedHandler calling f with the request's Context.
+func Typed[In, Out any](f func(ctx context.Context, in In) (Out, error)) *TypedHandler[In, Out] {
+	return &TypedHandler[In, Out]{f: f}
+}
+
+// HandleCx binds the request, calls the handler and renders its output.
+func (h *TypedHandler[In, Out]) HandleCx(res *WebhookResponse, req *WebhookRequest) error {
+	var in In
+	err := Bind(req, &in)
+	if err != nil {
+		var be *BindError
+		if !errors.As(err, &be) {
+			return err
+		}
+		if h.OnBindError != nil {
+			return h.OnBindError(res, req, be)
+		}
+		req.Logger().Warn(be.Error())
+		res.AddTextResponse(DefaultBindErrorText)
+		return nil
+	}
+	out, err := h.f(req.Context(), in)
+	if err != nil {
+		return err
+	}
+	return Render(res, req, out)
+}
+
+// Bind sets the cx-tagged fields of the struct pointed to by v from req's
+// parameters; see TypedHandler.
+func Bind(req *WebhookRequest, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("ezcx: Bind needs a pointer to a struct, got %T", v)
+	}
+	sources := map[string]func() map[string]any{
+		"form":    req.GetPageFormParameters,
+		"intent":  func() map[string]any { return intentParameters(req) },
+		"session": req.GetSessionParameters,
+		"payload": req.GetPayload,
+	}
+	cache := make(map[string]map[string]any)
+	params := func(source string) map[string]any {
+		m, ok := cache[source]
+		if !ok {
+			m = sources[source]()
+			cache[source] = m
+		}
+		return m
+	}
+	return eachTagged(rv.Elem(), func(f reflect.Value, sf reflect.StructField, name string, opts []string) error {
+		lookup := []string{"form", "intent", "session"}
+		required := false
+		for _, opt := range opts {
+			switch {
+			case opt == "required":
+				required = true
+			case sources[opt] != nil:
+				lookup = []string{opt}
+			default:
+				return fmt.Errorf("ezcx: field %s: unknown cx tag option %q", sf.Name, opt)
+			}
+		}
+		var value any
+		for _, source := range lookup {
+			if v, ok := params(source)[name]; ok && v != nil {
+				value = v
+				break
+			}
+		}
+		if value == nil {
+			if required {
+				return &BindError{sf.Name, name, ErrMissingParam}
+			}
+			return nil
+		}
+		b, err := json.Marshal(value)
+		if err == nil {
+			err = json.Unmarshal(b, f.Addr().Interface())
+		}
+		if err != nil {
+			return &BindError{sf.Name, name, err}
+		}
+		return nil
+	})
+}
+
+// Render writes out to res; see TypedHandler.
+func Render(res *WebhookResponse, req *WebhookRequest, out any) error {
+	if r, ok := out.(Renderer); ok {
+		err := r.Render(res, req)
+		if err != nil {
+			return err
+		}
+	}
+	rv := reflect.ValueOf(out)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	params := make(map[string]any)
+	err := eachTagged(rv, func(f reflect.Value, sf reflect.StructField, name string, opts []string) error {
+		for _, opt := range opts {
+			if opt == "omitempty" && f.IsZero() {
+				return nil
+			}
+		}
+		b, err := json.Marshal(f.Interface())
+		if err != nil {
+			return fmt.Errorf("ezcx: rendering %s: %w", sf.Name, err)
+		}
+		var v any
+		err = json.Unmarshal(b, &v)
+		params[name] = v
+		return err
+	})
+	if err != nil || len(params) == 0 {
+		return err
+	}
+	return res.AddSessionParameters(params)
+}
+
+// eachTagged calls f for each field of the struct v with a cx tag,
+// descending into untagged embedded structs.
+func eachTagged(v reflect.Value, f func(reflect.Value, reflect.StructField, string, []string) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("cx")
+		if !ok {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				err := eachTagged(v.Field(i), f)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		name, rest, _ := strings.Cut(tag, ",")
+		var opts []string
+		if rest != "" {
+			opts = strings.Split(rest, ",")
+		}
+		err := f(v.Field(i), sf, name, opts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func intentParameters(req *WebhookRequest) map[string]any {
+	params := make(map[string]any)
+	for k, v := range req.GetIntentInfo().GetParameters() {
+		params[k] = protoToAny(v.GetResolvedValue())
+	}
+	return params
+}
+
+// Reply is a declarative TypedHandler output.
+type Reply struct {
+	// Messages are text responses.
+	Messages []string
+	// Params are session parameter updates; a nil value deletes the
+	// parameter.
+	Params map[string]any
+	// Payload is merged into the response payload.
+	Payload map[string]any
+	// TargetPage or TargetFlow transitions the session.  Either may be a
+	// full resource name or an ID; a page ID, e.g. END_SESSION, is in the
+	// current flow and a flow ID is in the current agent.  Render fails if
+	// an ID is given and the request has no current page to resolve it
+	// against.
+	TargetPage string
+	TargetFlow string
+}
+
+// Render writes the Reply to res.
+func (r Reply) Render(res *WebhookResponse, req *WebhookRequest) error {
+	if len(r.Messages) > 0 {
+		res.AddTextResponse(r.Messages...)
+	}
+	if len(r.Params) > 0 {
+		err := res.AddSessionParameters(r.Params)
+		if err != nil {
+			return err
+		}
+	}
+	if len(r.Payload) > 0 {
+		err := res.AddPayload(r.Payload)
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case r.TargetPage != "":
+		target := r.TargetPage
+		if !strings.Contains(target, "/") {
+			page, err := ParsePageName(req.GetPageInfo().GetCurrentPage())
+			if err != nil {
+				return fmt.Errorf("ezcx: resolving target page %s: %w", target, err)
+			}
+			target = PageName{page.FlowName, target}.String()
+		}
+		res.Transition = &cx.WebhookResponse_TargetPage{TargetPage: target}
+	case r.TargetFlow != "":
+		target := r.TargetFlow
+		if !strings.Contains(target, "/") {
+			page, err := ParsePageName(req.GetPageInfo().GetCurrentPage())
+			if err != nil {
+				return fmt.Errorf("ezcx: resolving target flow %s: %w", target, err)
+			}
+			target = FlowName{page.AgentName, target}.String()
+		}
+		res.Transition = &cx.WebhookResponse_TargetFlow{TargetFlow: target}
+	}
+	return nil
+}
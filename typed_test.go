@@ -0,0 +1,154 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ezcx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	cx "cloud.google.com/go/dialogflow/cx/apiv3/cxpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type orderIn struct {
+	Size     string  `cx:"size,required"`
+	Quantity float64 `cx:"quantity"`
+	Customer struct {
+		Name string `json:"name"`
+	} `cx:"customer,session"`
+	Channel string `cx:"channel,payload"`
+	ignored string
+}
+
+type orderOut struct {
+	Reply
+	Total    float64 `cx:"total"`
+	Discount float64 `cx:"discount,omitempty"`
+}
+
+func typedRequest(t *testing.T, form, session map[string]any) *WebhookRequest {
+	t.Helper()
+	req := NewWebhookRequest()
+	req.SessionInfo = &cx.SessionInfo{Session: "projects/p/locations/global/agents/a/sessions/s"}
+	req.PageInfo = &cx.PageInfo{
+		CurrentPage: "projects/p/locations/global/agents/a/flows/f/pages/order",
+		FormInfo:    &cx.PageInfo_FormInfo{},
+	}
+	req.Payload = &structpb.Struct{}
+	err := req.setSessionParameters(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = req.setPayload(map[string]any{"channel": "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form != nil {
+		err = req.setPageFormParameters(form)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return req
+}
+
+func TestTyped(t *testing.T) {
+	h := Typed(func(ctx context.Context, in orderIn) (orderOut, error) {
+		if in.Customer.Name != "Ada" || in.Channel != "web" {
+			t.Errorf("in = %+v", in)
+		}
+		return orderOut{
+			Reply: Reply{
+				Messages:   []string{in.Size + " x" + strings.Repeat("I", int(in.Quantity))},
+				Params:     map[string]any{"pending": nil},
+				TargetPage: "END_SESSION",
+			},
+			Total: 3 * in.Quantity,
+		}, nil
+	})
+	req := typedRequest(t, map[string]any{"size": "large", "quantity": 2.0}, map[string]any{"customer": map[string]any{"name": "Ada"}, "quantity": 5.0})
+	res := req.InitializeResponse()
+	err := h.HandleCx(res, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.GetFulfillmentResponse().GetMessages()[0].GetText().GetText(); len(got) != 1 || got[0] != "large xII" {
+		t.Errorf("messages = %v", got)
+	}
+	params := res.GetSessionInfo().GetParameters()
+	if params["total"].GetNumberValue() != 6 {
+		t.Errorf("total = %v", params["total"])
+	}
+	if _, ok := params["pending"].GetKind().(*structpb.Value_NullValue); !ok {
+		t.Errorf("pending = %v, want null", params["pending"])
+	}
+	if _, ok := params["discount"]; ok {
+		t.Error("omitempty discount was set")
+	}
+	if got := res.GetTargetPage(); got != "projects/p/locations/global/agents/a/flows/f/pages/END_SESSION" {
+		t.Errorf("target page = %q", got)
+	}
+}
+
+func TestTypedBindError(t *testing.T) {
+	called := false
+	h := Typed(func(ctx context.Context, in orderIn) (Reply, error) {
+		called = true
+		return Reply{}, nil
+	})
+	req := typedRequest(t, map[string]any{"quantity": "two"}, nil)
+	res := req.InitializeResponse()
+	err := h.HandleCx(res, req)
+	if err != nil || called {
+		t.Fatalf("err = %v, called = %v", err, called)
+	}
+	if got := res.GetFulfillmentResponse().GetMessages()[0].GetText().GetText()[0]; got != DefaultBindErrorText {
+		t.Errorf("response = %q", got)
+	}
+
+	h.OnBindError = func(res *WebhookResponse, req *WebhookRequest, err *BindError) error {
+		if !errors.Is(err, ErrMissingParam) || err.Param != "size" {
+			t.Errorf("err = %v", err)
+		}
+		return err
+	}
+	err = h.HandleCx(req.InitializeResponse(), req)
+	var be *BindError
+	if !errors.As(err, &be) {
+		t.Errorf("err = %v", err)
+	}
+
+	var in orderIn
+	err = Bind(typedRequest(t, map[string]any{"size": "small", "quantity": "two"}, nil), &in)
+	if !errors.As(err, &be) || be.Field != "Quantity" {
+		t.Errorf("Bind = %v", err)
+	}
+}
+
+func TestReplyUnresolvedTarget(t *testing.T) {
+	req := NewWebhookRequest()
+	req.SessionInfo = &cx.SessionInfo{Session: "s"}
+	err := Reply{TargetPage: "END_SESSION"}.Render(req.InitializeResponse(), req)
+	if !errors.Is(err, ErrResourceName) {
+		t.Errorf("page ID without a current page: err = %v", err)
+	}
+	res := req.InitializeResponse()
+	err = Reply{TargetPage: "projects/p/locations/l/agents/a/flows/f/pages/END_SESSION"}.Render(res, req)
+	if err != nil || res.GetTargetPage() != "projects/p/locations/l/agents/a/flows/f/pages/END_SESSION" {
+		t.Errorf("full name: err = %v, target = %q", err, res.GetTargetPage())
+	}
+}